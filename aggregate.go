@@ -0,0 +1,147 @@
+package typemux
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"sync"
+)
+
+// ErrUnknownEvent is returned by Fold for an event without an apply function,
+// unless the aggregate was built with WithIgnoreUnknownEvents.
+var ErrUnknownEvent = errors.New("unknown event")
+
+// WithIgnoreUnknownEvents makes Fold skip events that have no apply function
+// instead of failing with ErrUnknownEvent. It only affects aggregates;
+// registries ignore it.
+func WithIgnoreUnknownEvents() Option {
+	return func(o *options) {
+		o.ignoreUnknown = true
+	}
+}
+
+type applyFunc[S any] func(state S, event any) S
+
+type folder[S any] interface {
+	applier(typ reflect.Type) (applyFunc[S], bool)
+	pointerPolicy() PointerPolicy
+	ignoresUnknown() bool
+}
+
+// Aggregate rebuilds state of type S from events, with one apply function per
+// event type. Use NewAggregate to create one, then RegisterApply.
+type Aggregate[S any] struct {
+	opts options
+
+	mu    sync.RWMutex
+	apply map[reflect.Type]applyFunc[S]
+}
+
+// NewAggregate creates a new empty Aggregate for state S. It honors
+// WithStrictDuplicates, WithPointerPolicy and WithIgnoreUnknownEvents.
+func NewAggregate[S any](opts ...Option) *Aggregate[S] {
+	return &Aggregate[S]{
+		opts:  newOptions(opts),
+		apply: make(map[reflect.Type]applyFunc[S]),
+	}
+}
+
+func (a *Aggregate[S]) registerApply(typ reflect.Type, fn applyFunc[S]) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.apply == nil {
+		a.apply = make(map[reflect.Type]applyFunc[S])
+	}
+
+	if _, ok := a.apply[typ]; ok && a.opts.strict {
+		panic(fmt.Errorf("typemux: %w: apply for event %v", ErrDuplicateRegistration, typ))
+	}
+
+	a.apply[typ] = fn
+}
+
+func (a *Aggregate[S]) applier(typ reflect.Type) (applyFunc[S], bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	fn, ok := a.apply[typ]
+	return fn, ok
+}
+
+func (a *Aggregate[S]) pointerPolicy() PointerPolicy {
+	return a.opts.pointers
+}
+
+func (a *Aggregate[S]) ignoresUnknown() bool {
+	return a.opts.ignoreUnknown
+}
+
+// Seal finalizes the Aggregate and returns a SealedAggregate.
+func (a *Aggregate[S]) Seal() *SealedAggregate[S] {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return &SealedAggregate[S]{opts: a.opts, apply: maps.Clone(a.apply)}
+}
+
+// SealedAggregate is an immutable Aggregate, safe for concurrent use with no
+// mutex overhead.
+type SealedAggregate[S any] struct {
+	opts  options
+	apply map[reflect.Type]applyFunc[S]
+}
+
+func (s *SealedAggregate[S]) applier(typ reflect.Type) (applyFunc[S], bool) {
+	fn, ok := s.apply[typ]
+	return fn, ok
+}
+
+func (s *SealedAggregate[S]) pointerPolicy() PointerPolicy {
+	return s.opts.pointers
+}
+
+func (s *SealedAggregate[S]) ignoresUnknown() bool {
+	return s.opts.ignoreUnknown
+}
+
+// RegisterApply adds the apply function for events of type E: given the
+// current state and an event, it returns the next state.
+//
+// If an apply function for the same type E has already been registered, it
+// will be replaced, unless the aggregate is in strict mode.
+func RegisterApply[S, E any](agg *Aggregate[S], apply func(state S, event E) S) {
+	typ := reflect.TypeOf((*E)(nil)).Elem()
+
+	agg.registerApply(typ, func(state S, event any) S {
+		return apply(state, event.(E))
+	})
+}
+
+// Fold applies events to initial in order and returns the resulting state.
+// Each event is resolved by its concrete type, with the pointer fallbacks of
+// the aggregate's PointerPolicy, as in Dispatch.
+//
+// An event without an apply function fails with ErrUnknownEvent, returned
+// together with the state folded up to that event; with
+// WithIgnoreUnknownEvents such events are skipped.
+func Fold[S any](agg folder[S], initial S, events []any) (S, error) {
+	state := initial
+	policy := agg.pointerPolicy()
+
+	for i, event := range events {
+		typ := reflect.TypeOf(event)
+
+		apply, arg, ok := lookupPointer(typ, event, policy, agg.applier)
+		if !ok {
+			if agg.ignoresUnknown() {
+				continue
+			}
+			return state, fmt.Errorf("typemux: %w: event %d of type %v", ErrUnknownEvent, i, typ)
+		}
+		state = apply(state, arg)
+	}
+
+	return state, nil
+}
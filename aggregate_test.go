@@ -0,0 +1,132 @@
+package typemux_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/struct0x/typemux"
+)
+
+type account struct {
+	Owner   string
+	Balance int
+	Closed  bool
+}
+
+type accountOpened struct{ Owner string }
+
+type deposited struct{ Amount int }
+
+type withdrawn struct{ Amount int }
+
+type accountClosed struct{}
+
+func newAccountAggregate(opts ...typemux.Option) *typemux.Aggregate[account] {
+	agg := typemux.NewAggregate[account](opts...)
+	typemux.RegisterApply(agg, func(s account, e accountOpened) account {
+		s.Owner = e.Owner
+		return s
+	})
+	typemux.RegisterApply(agg, func(s account, e deposited) account {
+		s.Balance += e.Amount
+		return s
+	})
+	typemux.RegisterApply(agg, func(s account, e *withdrawn) account {
+		s.Balance -= e.Amount
+		return s
+	})
+	return agg
+}
+
+func TestFold(t *testing.T) {
+	agg := newAccountAggregate()
+	events := []any{
+		accountOpened{Owner: "ann"},
+		deposited{Amount: 100},
+		&deposited{Amount: 50}, // pointer falls back to the value apply
+		&withdrawn{Amount: 30},
+	}
+
+	for name, fold := range map[string]func() (account, error){
+		"standard_aggregate": func() (account, error) { return typemux.Fold(agg, account{}, events) },
+		"sealed_aggregate":   func() (account, error) { return typemux.Fold(agg.Seal(), account{}, events) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			got, err := fold()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want := (account{Owner: "ann", Balance: 120}); got != want {
+				t.Errorf("expected %+v, got %+v", want, got)
+			}
+		})
+	}
+}
+
+func TestFold_UnknownEvent(t *testing.T) {
+	events := []any{deposited{Amount: 10}, accountClosed{}, deposited{Amount: 5}}
+
+	t.Run("rejected", func(t *testing.T) {
+		got, err := typemux.Fold(newAccountAggregate().Seal(), account{}, events)
+		if !errors.Is(err, typemux.ErrUnknownEvent) {
+			t.Fatalf("expected ErrUnknownEvent, got %v", err)
+		}
+		const want = "typemux: unknown event: event 1 of type typemux_test.accountClosed"
+		if err.Error() != want {
+			t.Errorf("expected %q, got %q", want, err.Error())
+		}
+		if got.Balance != 10 {
+			t.Errorf("expected state folded up to the unknown event, got %+v", got)
+		}
+	})
+
+	t.Run("ignored", func(t *testing.T) {
+		agg := newAccountAggregate(typemux.WithIgnoreUnknownEvents())
+		got, err := typemux.Fold(agg, account{}, append(events, nil))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Balance != 15 {
+			t.Errorf("expected 15, got %+v", got)
+		}
+	})
+
+	t.Run("value_without_pointer_policy", func(t *testing.T) {
+		// withdrawn is registered as *withdrawn; the default policy doesn't
+		// address values.
+		_, err := typemux.Fold(newAccountAggregate(), account{}, []any{withdrawn{Amount: 1}})
+		if !errors.Is(err, typemux.ErrUnknownEvent) {
+			t.Errorf("expected ErrUnknownEvent, got %v", err)
+		}
+
+		agg := newAccountAggregate(typemux.WithPointerPolicy(typemux.ValueToPointer))
+		got, err := typemux.Fold(agg, account{}, []any{withdrawn{Amount: 1}})
+		if err != nil || got.Balance != -1 {
+			t.Errorf("expected ValueToPointer fallback, got %+v, %v", got, err)
+		}
+	})
+}
+
+func TestAggregate_SealIsSnapshot(t *testing.T) {
+	agg := newAccountAggregate()
+	sealed := agg.Seal()
+
+	typemux.RegisterApply(agg, func(s account, e accountClosed) account {
+		s.Closed = true
+		return s
+	})
+
+	if _, err := typemux.Fold(sealed, account{}, []any{accountClosed{}}); !errors.Is(err, typemux.ErrUnknownEvent) {
+		t.Errorf("expected sealed aggregate to be unaffected, got %v", err)
+	}
+	if got, err := typemux.Fold(agg, account{}, []any{accountClosed{}}); err != nil || !got.Closed {
+		t.Errorf("expected apply registered after Seal, got %+v, %v", got, err)
+	}
+}
+
+func TestAggregate_StrictDuplicates(t *testing.T) {
+	agg := newAccountAggregate(typemux.WithStrictDuplicates())
+	expectDuplicatePanic(t, "deposited", func() {
+		typemux.RegisterApply(agg, func(s account, e deposited) account { return s })
+	})
+}
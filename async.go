@@ -0,0 +1,267 @@
+package typemux
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"sync"
+)
+
+// ErrQueueFull is returned by AsyncDispatcher.Submit under QueueError when
+// the queue has no room for the value.
+var ErrQueueFull = errors.New("async queue full")
+
+// ErrDropped is the result of a Future whose value was discarded under
+// QueueDrop because the queue was full.
+var ErrDropped = errors.New("dropped: async queue full")
+
+// ErrDispatcherClosed is returned by AsyncDispatcher.Submit and
+// PartitionedDispatcher.Submit after Shutdown has been called.
+var ErrDispatcherClosed = errors.New("async dispatcher closed")
+
+// QueuePolicy controls what AsyncDispatcher.Submit does when the queue is full.
+type QueuePolicy int
+
+const (
+	// QueueBlock blocks Submit until the queue has room or its context is done.
+	QueueBlock QueuePolicy = iota
+	// QueueDrop discards the value; Submit succeeds and the Future resolves
+	// with ErrDropped.
+	QueueDrop
+	// QueueError rejects the value; Submit returns ErrQueueFull.
+	QueueError
+)
+
+// AsyncConfig configures an AsyncDispatcher.
+type AsyncConfig struct {
+	// Workers is the number of goroutines dispatching from the queue.
+	// Defaults to runtime.GOMAXPROCS(0).
+	Workers int
+	// QueueSize is the number of values that may wait for a worker.
+	// Zero means values are handed to idle workers only.
+	QueueSize int
+	// Policy decides what happens when the queue is full.
+	Policy QueuePolicy
+	// Middleware is applied to every Dispatch, outermost-first.
+	Middleware []DispatchMiddleware
+}
+
+// Future is the pending result of a value submitted to an AsyncDispatcher.
+type Future struct {
+	done chan struct{}
+	err  error
+}
+
+func newFuture() *Future {
+	return &Future{done: make(chan struct{})}
+}
+
+func (f *Future) resolve(err error) {
+	f.err = err
+	close(f.done)
+}
+
+// Done returns a channel that is closed once the value has been handled.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Err returns the dispatch result. It must only be called after Done is closed.
+func (f *Future) Err() error {
+	return f.err
+}
+
+// Wait blocks until the value has been handled or ctx is done, and returns
+// the dispatch result or the context's error.
+func (f *Future) Wait(ctx context.Context) error {
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type asyncJob struct {
+	ctx    context.Context
+	v      any
+	future *Future
+}
+
+// workerPool runs the workers shared by AsyncDispatcher and
+// PartitionedDispatcher and guards the queues they read from, so that Submit
+// never sends on a queue closed by Shutdown.
+type workerPool struct {
+	disp dispatcher
+	mw   []DispatchMiddleware
+
+	// done is closed when Shutdown starts, releasing Submit calls blocked on
+	// a full queue so that shutdown can take mu.
+	done      chan struct{}
+	closeDone sync.Once
+
+	mu     sync.RWMutex
+	closed bool
+
+	workers sync.WaitGroup
+}
+
+func newWorkerPool(disp dispatcher, mw []DispatchMiddleware) workerPool {
+	return workerPool{disp: disp, mw: mw, done: make(chan struct{})}
+}
+
+// start runs a worker dispatching the jobs of queue until it is closed.
+func (w *workerPool) start(queue <-chan asyncJob) {
+	w.workers.Add(1)
+	go func() {
+		defer w.workers.Done()
+		for job := range queue {
+			job.future.resolve(w.dispatch(job))
+		}
+	}()
+}
+
+// dispatch handles one job. A panic is returned as a *PanicError, so it
+// resolves the job's Future instead of killing the worker.
+func (w *workerPool) dispatch(job asyncJob) error {
+	var cfg RecoverConfig
+	return cfg.guard(job.ctx, reflect.TypeOf(job.v), func(ctx context.Context) error {
+		return Dispatch(w.disp, ctx, job.v, w.mw...)
+	})
+}
+
+// enter reports whether the pool still accepts jobs. If it does, the queues
+// stay open until the matching leave.
+func (w *workerPool) enter() bool {
+	w.mu.RLock()
+	if w.closed {
+		w.mu.RUnlock()
+		return false
+	}
+	return true
+}
+
+func (w *workerPool) leave() {
+	w.mu.RUnlock()
+}
+
+// send queues job, blocking until there is room, ctx is done or the pool
+// shuts down. It must be called between enter and leave.
+func (w *workerPool) send(ctx context.Context, queue chan<- asyncJob, job asyncJob) error {
+	select {
+	case queue <- job:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-w.done:
+		return ErrDispatcherClosed
+	}
+}
+
+// shutdown stops accepting jobs, calling closeQueues once, and waits for the
+// workers to drain the queues or ctx to be done, whichever is first.
+func (w *workerPool) shutdown(ctx context.Context, closeQueues func()) error {
+	w.closeDone.Do(func() { close(w.done) })
+
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		closeQueues()
+	}
+	w.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		w.workers.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// AsyncDispatcher dispatches values on a bounded pool of worker goroutines,
+// so callers such as HTTP handlers can return before the handlers finish.
+// A panicking handler resolves its Future with a *PanicError.
+//
+// It wraps any Registry, SealedRegistry or dispatch-only registry. Use
+// NewAsyncDispatcher() to create one and Shutdown() to stop it.
+type AsyncDispatcher struct {
+	pool   workerPool
+	policy QueuePolicy
+	queue  chan asyncJob
+}
+
+// NewAsyncDispatcher starts the workers of a new AsyncDispatcher over disp.
+func NewAsyncDispatcher(disp dispatcher, cfg AsyncConfig) *AsyncDispatcher {
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	queueSize := max(cfg.QueueSize, 0)
+
+	a := &AsyncDispatcher{
+		pool:   newWorkerPool(disp, cfg.Middleware),
+		policy: cfg.Policy,
+		queue:  make(chan asyncJob, queueSize),
+	}
+
+	for range workers {
+		a.pool.start(a.queue)
+	}
+
+	return a
+}
+
+// Submit queues v for dispatch and returns a Future for its result.
+//
+// The handler runs with a context that keeps ctx's values but not its
+// cancellation, so the submitting request may finish first. Under QueueBlock,
+// ctx bounds only the wait for room in the queue.
+//
+// Returns:
+//   - ErrDispatcherClosed if Shutdown has been called, including while blocked
+//     under QueueBlock
+//   - ErrQueueFull if the queue is full under QueueError
+//   - the context's error if ctx is done while blocked under QueueBlock
+func (a *AsyncDispatcher) Submit(ctx context.Context, v any) (*Future, error) {
+	if !a.pool.enter() {
+		return nil, ErrDispatcherClosed
+	}
+	defer a.pool.leave()
+
+	job := asyncJob{ctx: context.WithoutCancel(ctx), v: v, future: newFuture()}
+
+	switch a.policy {
+	case QueueDrop:
+		select {
+		case a.queue <- job:
+		default:
+			job.future.resolve(ErrDropped)
+		}
+	case QueueError:
+		select {
+		case a.queue <- job:
+		default:
+			return nil, ErrQueueFull
+		}
+	default:
+		if err := a.pool.send(ctx, a.queue, job); err != nil {
+			return nil, err
+		}
+	}
+
+	return job.future, nil
+}
+
+// Shutdown stops accepting new values and waits for queued and in-flight
+// values to be handled. If ctx is done first, Shutdown returns the context's
+// error; the workers still finish draining in the background.
+func (a *AsyncDispatcher) Shutdown(ctx context.Context) error {
+	return a.pool.shutdown(ctx, func() { close(a.queue) })
+}
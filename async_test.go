@@ -0,0 +1,290 @@
+package typemux_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/struct0x/typemux"
+)
+
+func TestAsyncDispatcher_Submit(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	errBoom := errors.New("boom")
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error {
+		if e.Name == "fail" {
+			return errBoom
+		}
+		return nil
+	})
+
+	async := typemux.NewAsyncDispatcher(reg.Seal(), typemux.AsyncConfig{Workers: 2, QueueSize: 4})
+	defer func() { _ = async.Shutdown(context.Background()) }()
+
+	ok, err := async.Submit(context.Background(), testEvent{Name: "ok"})
+	if err != nil {
+		t.Fatalf("unexpected submit error: %v", err)
+	}
+	failed, err := async.Submit(context.Background(), testEvent{Name: "fail"})
+	if err != nil {
+		t.Fatalf("unexpected submit error: %v", err)
+	}
+	unknown, err := async.Submit(context.Background(), "unknown")
+	if err != nil {
+		t.Fatalf("unexpected submit error: %v", err)
+	}
+
+	if err := ok.Wait(context.Background()); err != nil {
+		t.Errorf("expected success, got %v", err)
+	}
+	if err := failed.Wait(context.Background()); !errors.Is(err, errBoom) {
+		t.Errorf("expected handler error, got %v", err)
+	}
+	<-unknown.Done()
+	if !errors.Is(unknown.Err(), typemux.ErrHandlerNotFound) {
+		t.Errorf("expected ErrHandlerNotFound, got %v", unknown.Err())
+	}
+}
+
+func TestAsyncDispatcher_DetachesCancellation(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	release := make(chan struct{})
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error {
+		<-release
+		return ctx.Err()
+	})
+
+	async := typemux.NewAsyncDispatcher(reg, typemux.AsyncConfig{Workers: 1})
+	defer func() { _ = async.Shutdown(context.Background()) }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f, err := async.Submit(ctx, testEvent{})
+	if err != nil {
+		t.Fatalf("unexpected submit error: %v", err)
+	}
+
+	// The submitting request finishes before the handler does.
+	cancel()
+	close(release)
+
+	if err := f.Wait(context.Background()); err != nil {
+		t.Errorf("expected handler context to outlive the caller, got %v", err)
+	}
+}
+
+func TestAsyncDispatcher_QueuePolicies(t *testing.T) {
+	newBlocked := func(policy typemux.QueuePolicy) (*typemux.AsyncDispatcher, chan struct{}) {
+		reg := typemux.NewRegistry()
+
+		started := make(chan struct{}, 1)
+		release := make(chan struct{})
+		typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error {
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			<-release
+			return nil
+		})
+
+		async := typemux.NewAsyncDispatcher(reg, typemux.AsyncConfig{Workers: 1, QueueSize: 1, Policy: policy})
+
+		// Occupy the single worker, then fill the single queue slot.
+		if _, err := async.Submit(context.Background(), testEvent{}); err != nil {
+			t.Fatalf("unexpected submit error: %v", err)
+		}
+		<-started
+		if _, err := async.Submit(context.Background(), testEvent{}); err != nil {
+			t.Fatalf("unexpected submit error: %v", err)
+		}
+		return async, release
+	}
+
+	t.Run("error", func(t *testing.T) {
+		async, release := newBlocked(typemux.QueueError)
+		defer func() { close(release); _ = async.Shutdown(context.Background()) }()
+
+		if _, err := async.Submit(context.Background(), testEvent{}); !errors.Is(err, typemux.ErrQueueFull) {
+			t.Errorf("expected ErrQueueFull, got %v", err)
+		}
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		async, release := newBlocked(typemux.QueueDrop)
+		defer func() { close(release); _ = async.Shutdown(context.Background()) }()
+
+		f, err := async.Submit(context.Background(), testEvent{})
+		if err != nil {
+			t.Fatalf("expected drop to succeed, got %v", err)
+		}
+		if err := f.Wait(context.Background()); !errors.Is(err, typemux.ErrDropped) {
+			t.Errorf("expected ErrDropped, got %v", err)
+		}
+	})
+
+	t.Run("block", func(t *testing.T) {
+		async, release := newBlocked(typemux.QueueBlock)
+		defer func() { close(release); _ = async.Shutdown(context.Background()) }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		if _, err := async.Submit(ctx, testEvent{}); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded, got %v", err)
+		}
+	})
+}
+
+func TestAsyncDispatcher_Panic(t *testing.T) {
+	reg := typemux.NewRegistry()
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error {
+		if e.Name == "boom" {
+			panic("boom")
+		}
+		return nil
+	})
+
+	async := typemux.NewAsyncDispatcher(reg, typemux.AsyncConfig{Workers: 1})
+	defer async.Shutdown(context.Background())
+
+	f, err := async.Submit(context.Background(), testEvent{Name: "boom"})
+	if err != nil {
+		t.Fatalf("unexpected submit error: %v", err)
+	}
+	var p *typemux.PanicError
+	if err := f.Wait(context.Background()); !errors.As(err, &p) || p.Value != "boom" {
+		t.Fatalf("expected *PanicError for boom, got %v", err)
+	}
+
+	// The worker survives the panic.
+	f, err = async.Submit(context.Background(), testEvent{})
+	if err != nil {
+		t.Fatalf("unexpected submit error: %v", err)
+	}
+	if err := f.Wait(context.Background()); err != nil {
+		t.Errorf("unexpected error after a panic: %v", err)
+	}
+}
+
+func TestAsyncDispatcher_ShutdownDrains(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	var handled atomic.Int32
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error {
+		time.Sleep(time.Millisecond)
+		handled.Add(1)
+		return nil
+	})
+
+	async := typemux.NewAsyncDispatcher(reg.Seal(), typemux.AsyncConfig{Workers: 2, QueueSize: 16})
+
+	for range 10 {
+		if _, err := async.Submit(context.Background(), testEvent{}); err != nil {
+			t.Fatalf("unexpected submit error: %v", err)
+		}
+	}
+
+	if err := async.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected shutdown error: %v", err)
+	}
+	if handled.Load() != 10 {
+		t.Errorf("expected all 10 values handled, got %d", handled.Load())
+	}
+
+	if _, err := async.Submit(context.Background(), testEvent{}); !errors.Is(err, typemux.ErrDispatcherClosed) {
+		t.Errorf("expected ErrDispatcherClosed, got %v", err)
+	}
+}
+
+func TestAsyncDispatcher_ShutdownTimeout(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	release := make(chan struct{})
+	defer close(release)
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error {
+		<-release
+		return nil
+	})
+
+	async := typemux.NewAsyncDispatcher(reg, typemux.AsyncConfig{Workers: 1})
+	if _, err := async.Submit(context.Background(), testEvent{}); err != nil {
+		t.Fatalf("unexpected submit error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := async.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestAsyncDispatcher_ShutdownReleasesBlockedSubmit(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	defer close(release)
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		return nil
+	})
+
+	async := typemux.NewAsyncDispatcher(reg, typemux.AsyncConfig{Workers: 1, Policy: typemux.QueueBlock})
+	if _, err := async.Submit(context.Background(), testEvent{}); err != nil {
+		t.Fatalf("unexpected submit error: %v", err)
+	}
+	<-started
+
+	blocked := newWaitingContext()
+	submitted := make(chan error, 1)
+	go func() {
+		_, err := async.Submit(blocked, testEvent{})
+		submitted <- err
+	}()
+	<-blocked.waiting // Submit is waiting for room in the queue
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- async.Shutdown(ctx) }()
+
+	select {
+	case err := <-shutdown:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown did not honour its context")
+	}
+	if err := <-submitted; !errors.Is(err, typemux.ErrDispatcherClosed) {
+		t.Errorf("expected ErrDispatcherClosed, got %v", err)
+	}
+}
+
+// waitingContext closes waiting the first time Done is called, which Submit
+// only does once it has to wait for room in a full queue.
+type waitingContext struct {
+	context.Context
+	waiting chan struct{}
+	once    sync.Once
+}
+
+func newWaitingContext() *waitingContext {
+	return &waitingContext{Context: context.Background(), waiting: make(chan struct{})}
+}
+
+func (c *waitingContext) Done() <-chan struct{} {
+	c.once.Do(func() { close(c.waiting) })
+	return c.Context.Done()
+}
@@ -0,0 +1,135 @@
+package typemux
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+type batchFuncAny func(ctx context.Context, vals []any) error
+
+type batchRegistry interface {
+	registerBatch(reflect.Type, batchFuncAny)
+}
+
+type batchDispatcher interface {
+	dispatcher
+	batchHandler(typ reflect.Type) (batchFuncAny, bool)
+	pointerPolicy() PointerPolicy
+}
+
+// ItemError records the failure of one value passed to DispatchBatch.
+type ItemError struct {
+	// Index is the position of the value in the slice given to DispatchBatch.
+	Index int
+	Value any
+	Err   error
+}
+
+// BatchError is returned by DispatchBatch when one or more values failed.
+// Failures are listed in input order.
+type BatchError struct {
+	Failures []ItemError
+	Total    int
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("typemux: %d of %d batch items failed, first at index %d: %v",
+		len(e.Failures), e.Total, e.Failures[0].Index, e.Failures[0].Err)
+}
+
+// Unwrap returns the errors of every failed item, so errors.Is and errors.As
+// see through a BatchError.
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, len(e.Failures))
+	for i, f := range e.Failures {
+		errs[i] = f.Err
+	}
+	return errs
+}
+
+// RegisterBatchDispatch adds a batch handler receiving values of type T in
+// bulk from DispatchBatch, with optional middleware.
+//
+// Batch handlers are independent of RegisterDispatch handlers: Dispatch never
+// calls them. If a batch handler for the same type T has already been
+// registered, it will be replaced.
+//
+// Middleware is applied outermost first, as with RegisterDispatch.
+func RegisterBatchDispatch[T any](reg batchRegistry, handler HandlerFunc[[]T], middleware ...Middleware[[]T]) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	final := applyMiddleware(handler, middleware...)
+
+	reg.registerBatch(typ, func(ctx context.Context, vals []any) error {
+		batch := make([]T, len(vals))
+		for i, v := range vals {
+			val, ok := v.(T)
+			if !ok {
+				return fmt.Errorf("typemux: expected %v, got %T", typ, v)
+			}
+			batch[i] = val
+		}
+		return final(ctx, batch)
+	})
+}
+
+type batchGroup struct {
+	handler batchFuncAny
+	idx     []int
+	vals    []any
+}
+
+// DispatchBatch groups values by concrete type and hands each group to the
+// batch handler registered for it. Pointer fallbacks follow the registry's
+// PointerPolicy, as in Dispatch. Values without a batch handler are
+// dispatched one by one as they are encountered, exactly as Dispatch would.
+//
+// Batch groups then run in order of first appearance; within a group, values
+// keep their input order. If any value fails, DispatchBatch returns a
+// *BatchError listing each failed value — every value of a group whose batch
+// handler failed is reported with that handler's error.
+func DispatchBatch(disp batchDispatcher, ctx context.Context, values []any) error {
+	var (
+		groups   []*batchGroup
+		byType   = make(map[reflect.Type]*batchGroup)
+		failures []ItemError
+	)
+
+	for i, v := range values {
+		typ := reflect.TypeOf(v)
+
+		handler, arg, ok := lookupPointer(typ, v, disp.pointerPolicy(), disp.batchHandler)
+		if !ok {
+			if err := disp.call(typ, ctx, v); err != nil {
+				failures = append(failures, ItemError{Index: i, Value: v, Err: err})
+			}
+			continue
+		}
+
+		key := reflect.TypeOf(arg)
+		g, ok := byType[key]
+		if !ok {
+			g = &batchGroup{handler: handler}
+			byType[key] = g
+			groups = append(groups, g)
+		}
+		g.idx = append(g.idx, i)
+		g.vals = append(g.vals, arg)
+	}
+
+	for _, g := range groups {
+		if err := g.handler(ctx, g.vals); err != nil {
+			for _, i := range g.idx {
+				failures = append(failures, ItemError{Index: i, Value: values[i], Err: err})
+			}
+		}
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+
+	slices.SortFunc(failures, func(a, b ItemError) int { return a.Index - b.Index })
+	return &BatchError{Failures: failures, Total: len(values)}
+}
@@ -0,0 +1,116 @@
+package typemux_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/struct0x/typemux"
+)
+
+func TestDispatchBatch_GroupsByType(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	var users [][]UserCreated
+	typemux.RegisterBatchDispatch(reg, func(ctx context.Context, batch []UserCreated) error {
+		users = append(users, batch)
+		return nil
+	})
+
+	var orders []OrderPlaced
+	typemux.RegisterDispatch(reg, func(ctx context.Context, o OrderPlaced) error {
+		orders = append(orders, o)
+		return nil
+	})
+
+	values := []any{
+		UserCreated{ID: "u1"},
+		OrderPlaced{OrderID: "o1"},
+		&UserCreated{ID: "u2"}, // pointer falls back to the value batch handler
+		OrderPlaced{OrderID: "o2"},
+		UserCreated{ID: "u3"},
+	}
+
+	for name, run := range map[string]func() error{
+		"standard_registry": func() error { return typemux.DispatchBatch(reg, context.Background(), values) },
+		"sealed_registry":   func() error { return typemux.DispatchBatch(reg.Seal(), context.Background(), values) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			users, orders = nil, nil
+
+			if err := run(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			wantUsers := [][]UserCreated{{{ID: "u1"}, {ID: "u2"}, {ID: "u3"}}}
+			if !reflect.DeepEqual(users, wantUsers) {
+				t.Errorf("expected one batch %v, got %v", wantUsers, users)
+			}
+			wantOrders := []OrderPlaced{{OrderID: "o1"}, {OrderID: "o2"}}
+			if !reflect.DeepEqual(orders, wantOrders) {
+				t.Errorf("expected per-item dispatch %v, got %v", wantOrders, orders)
+			}
+		})
+	}
+}
+
+func TestDispatchBatch_ReportsItemFailures(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	errBulk := errors.New("bulk insert failed")
+	typemux.RegisterBatchDispatch(reg, func(ctx context.Context, batch []UserCreated) error {
+		return errBulk
+	})
+
+	values := []any{
+		UserCreated{ID: "u1"},
+		"no handler",
+		UserCreated{ID: "u2"},
+	}
+
+	err := typemux.DispatchBatch(reg, context.Background(), values)
+
+	var batchErr *typemux.BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("expected *BatchError, got %v", err)
+	}
+	if batchErr.Total != 3 || len(batchErr.Failures) != 3 {
+		t.Fatalf("expected 3 of 3 failures, got %d of %d", len(batchErr.Failures), batchErr.Total)
+	}
+	for i, f := range batchErr.Failures {
+		if f.Index != i {
+			t.Errorf("expected failures in input order, got index %d at %d", f.Index, i)
+		}
+	}
+	if !errors.Is(batchErr.Failures[1].Err, typemux.ErrHandlerNotFound) {
+		t.Errorf("expected ErrHandlerNotFound for item 1, got %v", batchErr.Failures[1].Err)
+	}
+	if !errors.Is(err, errBulk) || !errors.Is(err, typemux.ErrHandlerNotFound) {
+		t.Errorf("expected BatchError to unwrap to both causes, got %v", err)
+	}
+}
+
+func TestDispatchBatch_Middleware(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	var sizes []int
+	sizeLogger := func(next typemux.HandlerFunc[[]UserCreated]) typemux.HandlerFunc[[]UserCreated] {
+		return func(ctx context.Context, batch []UserCreated) error {
+			sizes = append(sizes, len(batch))
+			return next(ctx, batch)
+		}
+	}
+
+	typemux.RegisterBatchDispatch(reg, func(ctx context.Context, batch []UserCreated) error {
+		return nil
+	}, sizeLogger)
+
+	values := []any{UserCreated{ID: "u1"}, UserCreated{ID: "u2"}}
+	if err := typemux.DispatchBatch(reg, context.Background(), values); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(sizes, []int{2}) {
+		t.Errorf("expected one batch of 2, got %v", sizes)
+	}
+}
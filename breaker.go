@@ -0,0 +1,247 @@
+package typemux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by circuit breaker middleware instead of calling
+// the handler while the circuit for the value's type is open.
+var ErrCircuitOpen = errors.New("circuit open")
+
+// Circuit breaker defaults, used when BreakerConfig leaves a field zero.
+const (
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 30 * time.Second
+)
+
+// CircuitState is the state of the circuit for one type.
+type CircuitState int
+
+const (
+	// CircuitClosed lets every call through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects every call with ErrCircuitOpen.
+	CircuitOpen
+	// CircuitHalfOpen lets one trial call through at a time.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(s))
+	}
+}
+
+// BreakerConfig configures a CircuitBreaker.
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens a
+	// closed circuit. Defaults to DefaultBreakerThreshold.
+	FailureThreshold int
+	// Cooldown is how long a circuit stays open before a trial call is let
+	// through. Defaults to DefaultBreakerCooldown.
+	Cooldown time.Duration
+	// SuccessThreshold is the number of consecutive successful trial calls
+	// that closes a half-open circuit. Defaults to 1.
+	SuccessThreshold int
+	// IsFailure decides which handler errors count as failures. Defaults to
+	// every non-nil error.
+	IsFailure func(err error) bool
+	// OnStateChange, if set, is called after the circuit of typ changes
+	// state, outside the breaker's locks.
+	OnStateChange func(typ reflect.Type, from, to CircuitState)
+	// Clock is used to time the cooldown. Defaults to the system clock.
+	Clock Clock
+}
+
+// CircuitBreaker keeps one circuit per dispatched type, so a failing handler
+// stops being called without affecting the handlers of other types. Use
+// NewCircuitBreaker to create one, then attach Middleware or CircuitFor.
+type CircuitBreaker struct {
+	cfg   BreakerConfig
+	clock Clock
+
+	// circuits holds reflect.Type -> *circuit.
+	circuits sync.Map
+}
+
+type circuit struct {
+	mu        sync.Mutex
+	state     CircuitState
+	failures  int
+	successes int
+	openedAt  time.Time
+	trial     bool // a half-open trial call is in flight
+	// epoch counts state changes; a call only records its outcome in the
+	// epoch it was admitted in.
+	epoch uint64
+}
+
+// NewCircuitBreaker creates a CircuitBreaker with all circuits closed.
+func NewCircuitBreaker(cfg BreakerConfig) *CircuitBreaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = DefaultBreakerThreshold
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = DefaultBreakerCooldown
+	}
+	if cfg.SuccessThreshold <= 0 {
+		cfg.SuccessThreshold = 1
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = func(err error) bool { return err != nil }
+	}
+	return &CircuitBreaker{cfg: cfg, clock: clockOrSystem(cfg.Clock)}
+}
+
+// Middleware returns a DispatchMiddleware guarding each dispatch with the
+// circuit of the value's concrete type.
+func (b *CircuitBreaker) Middleware() DispatchMiddleware {
+	return func(ctx context.Context, event any, next func(context.Context) error) error {
+		return b.do(reflect.TypeOf(event), ctx, next)
+	}
+}
+
+// CircuitFor returns a typed Middleware guarding the handler for T with the
+// circuit of T in b.
+func CircuitFor[T any](b *CircuitBreaker) Middleware[T] {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	return func(next HandlerFunc[T]) HandlerFunc[T] {
+		return func(ctx context.Context, val T) error {
+			return b.do(typ, ctx, func(ctx context.Context) error {
+				return next(ctx, val)
+			})
+		}
+	}
+}
+
+// State returns the current state of the circuit for typ. An open circuit
+// whose cooldown has elapsed reports CircuitOpen until the next call.
+func (b *CircuitBreaker) State(typ reflect.Type) CircuitState {
+	c, ok := b.circuits.Load(typ)
+	if !ok {
+		return CircuitClosed
+	}
+
+	cc := c.(*circuit)
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.state
+}
+
+func (b *CircuitBreaker) circuit(typ reflect.Type) *circuit {
+	if c, ok := b.circuits.Load(typ); ok {
+		return c.(*circuit)
+	}
+	c, _ := b.circuits.LoadOrStore(typ, &circuit{})
+	return c.(*circuit)
+}
+
+func (b *CircuitBreaker) do(typ reflect.Type, ctx context.Context, call func(context.Context) error) error {
+	c := b.circuit(typ)
+
+	epoch, allowed, from, to := b.allow(c)
+	b.notify(typ, from, to)
+	if !allowed {
+		return fmt.Errorf("typemux: %w for type %v", ErrCircuitOpen, typ)
+	}
+
+	// Recording is deferred so that a panicking call counts as a failure
+	// instead of leaving a half-open trial in flight forever.
+	failed := true
+	defer func() {
+		from, to := b.record(c, epoch, failed)
+		b.notify(typ, from, to)
+	}()
+
+	err := call(ctx)
+	failed = b.cfg.IsFailure(err)
+	return err
+}
+
+// allow decides whether a call may proceed, returning the epoch it is
+// admitted in, and reports any state change.
+func (b *CircuitBreaker) allow(c *circuit) (epoch uint64, allowed bool, from, to CircuitState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	from = c.state
+	switch c.state {
+	case CircuitOpen:
+		if b.clock.Now().Sub(c.openedAt) < b.cfg.Cooldown {
+			return c.epoch, false, from, from
+		}
+		c.set(CircuitHalfOpen)
+		c.successes = 0
+		c.trial = true
+		return c.epoch, true, from, c.state
+	case CircuitHalfOpen:
+		if c.trial {
+			return c.epoch, false, from, from
+		}
+		c.trial = true
+		return c.epoch, true, from, from
+	default:
+		return c.epoch, true, from, from
+	}
+}
+
+// record accounts the outcome of a call admitted in epoch and reports any
+// state change. The outcome of a call admitted before the circuit last
+// changed state is stale and ignored; in particular, only the trial call
+// admitted while half-open can close or reopen the circuit.
+func (b *CircuitBreaker) record(c *circuit, epoch uint64, failed bool) (from, to CircuitState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	from = c.state
+	if epoch != c.epoch {
+		return from, from
+	}
+	switch c.state {
+	case CircuitHalfOpen:
+		c.trial = false
+		if failed {
+			b.open(c)
+		} else if c.successes++; c.successes >= b.cfg.SuccessThreshold {
+			c.set(CircuitClosed)
+			c.failures = 0
+		}
+	case CircuitClosed:
+		if !failed {
+			c.failures = 0
+		} else if c.failures++; c.failures >= b.cfg.FailureThreshold {
+			b.open(c)
+		}
+	}
+	return from, c.state
+}
+
+func (b *CircuitBreaker) open(c *circuit) {
+	c.set(CircuitOpen)
+	c.openedAt = b.clock.Now()
+	c.failures = 0
+}
+
+// set moves c to state, starting a new epoch.
+func (c *circuit) set(state CircuitState) {
+	c.state = state
+	c.epoch++
+}
+
+func (b *CircuitBreaker) notify(typ reflect.Type, from, to CircuitState) {
+	if from != to && b.cfg.OnStateChange != nil {
+		b.cfg.OnStateChange(typ, from, to)
+	}
+}
@@ -0,0 +1,265 @@
+package typemux_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/struct0x/typemux"
+)
+
+func TestCircuitBreaker_Lifecycle(t *testing.T) {
+	clock := newFakeClock()
+	var changes []string
+	breaker := typemux.NewCircuitBreaker(typemux.BreakerConfig{
+		FailureThreshold: 2,
+		Cooldown:         time.Minute,
+		Clock:            clock,
+		OnStateChange: func(typ reflect.Type, from, to typemux.CircuitState) {
+			changes = append(changes, fmt.Sprintf("%v: %v -> %v", typ, from, to))
+		},
+	})
+
+	var fail bool
+	var calls int
+	reg := typemux.NewRegistry()
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error {
+		calls++
+		if fail {
+			return errDeadlock
+		}
+		return nil
+	})
+	typemux.RegisterDispatch(reg, func(ctx context.Context, o OrderPlaced) error { return nil })
+
+	dispatch := func(v any) error {
+		return typemux.Dispatch(reg, context.Background(), v, breaker.Middleware())
+	}
+	typ := reflect.TypeOf(testEvent{})
+
+	fail = true
+	for range 2 {
+		if err := dispatch(testEvent{}); !errors.Is(err, errDeadlock) {
+			t.Fatalf("expected handler error, got %v", err)
+		}
+	}
+	if breaker.State(typ) != typemux.CircuitOpen {
+		t.Fatalf("expected open circuit, got %v", breaker.State(typ))
+	}
+
+	err := dispatch(testEvent{})
+	if !errors.Is(err, typemux.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	const want = "typemux: circuit open for type typemux_test.testEvent"
+	if err.Error() != want {
+		t.Errorf("expected %q, got %q", want, err.Error())
+	}
+	if calls != 2 {
+		t.Errorf("expected the handler not to be called while open, got %d calls", calls)
+	}
+
+	// Other types have their own circuit.
+	if err := dispatch(OrderPlaced{}); err != nil {
+		t.Errorf("expected OrderPlaced unaffected, got %v", err)
+	}
+
+	// After the cooldown a failing trial reopens the circuit...
+	clock.Advance(time.Minute)
+	if err := dispatch(testEvent{}); !errors.Is(err, errDeadlock) {
+		t.Fatalf("expected trial call, got %v", err)
+	}
+	if err := dispatch(testEvent{}); !errors.Is(err, typemux.ErrCircuitOpen) {
+		t.Fatalf("expected reopened circuit, got %v", err)
+	}
+
+	// ...and a successful one closes it.
+	clock.Advance(time.Minute)
+	fail = false
+	if err := dispatch(testEvent{}); err != nil {
+		t.Fatalf("expected trial call to succeed, got %v", err)
+	}
+	if breaker.State(typ) != typemux.CircuitClosed {
+		t.Errorf("expected closed circuit, got %v", breaker.State(typ))
+	}
+
+	wantChanges := []string{
+		"typemux_test.testEvent: closed -> open",
+		"typemux_test.testEvent: open -> half-open",
+		"typemux_test.testEvent: half-open -> open",
+		"typemux_test.testEvent: open -> half-open",
+		"typemux_test.testEvent: half-open -> closed",
+	}
+	if !reflect.DeepEqual(changes, wantChanges) {
+		t.Errorf("expected %v, got %v", wantChanges, changes)
+	}
+}
+
+func TestCircuitBreaker_HalfOpenSingleTrial(t *testing.T) {
+	clock := newFakeClock()
+	breaker := typemux.NewCircuitBreaker(typemux.BreakerConfig{
+		FailureThreshold: 1,
+		SuccessThreshold: 2,
+		Cooldown:         time.Second,
+		Clock:            clock,
+	})
+
+	release := make(chan struct{})
+	entered := make(chan struct{}, 1)
+	var block bool
+	handler := typemux.CircuitFor[testEvent](breaker)(func(ctx context.Context, e testEvent) error {
+		if block {
+			entered <- struct{}{}
+			<-release
+			return nil
+		}
+		return errDeadlock
+	})
+
+	_ = handler(context.Background(), testEvent{})
+	clock.Advance(time.Second)
+
+	block = true
+	done := make(chan error)
+	go func() { done <- handler(context.Background(), testEvent{}) }()
+	<-entered
+
+	if err := handler(context.Background(), testEvent{}); !errors.Is(err, typemux.ErrCircuitOpen) {
+		t.Errorf("expected concurrent call rejected during the trial, got %v", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected trial error: %v", err)
+	}
+
+	typ := reflect.TypeOf(testEvent{})
+	if breaker.State(typ) != typemux.CircuitHalfOpen {
+		t.Errorf("expected half-open until SuccessThreshold trials pass, got %v", breaker.State(typ))
+	}
+	block = false
+	// A second successful trial would close it; this one fails instead.
+	if err := handler(context.Background(), testEvent{}); !errors.Is(err, errDeadlock) {
+		t.Fatalf("expected trial error, got %v", err)
+	}
+	if breaker.State(typ) != typemux.CircuitOpen {
+		t.Errorf("expected open circuit, got %v", breaker.State(typ))
+	}
+}
+
+func TestCircuitBreaker_StaleResultIgnored(t *testing.T) {
+	clock := newFakeClock()
+	breaker := typemux.NewCircuitBreaker(typemux.BreakerConfig{
+		FailureThreshold: 1,
+		Cooldown:         time.Second,
+		Clock:            clock,
+	})
+
+	// Calls named "slow" block until their release channel yields a result.
+	type releaseKey struct{}
+	entered := make(chan struct{})
+	handler := typemux.CircuitFor[testEvent](breaker)(func(ctx context.Context, e testEvent) error {
+		if e.Name != "slow" {
+			return errDeadlock
+		}
+		entered <- struct{}{}
+		return <-ctx.Value(releaseKey{}).(chan error)
+	})
+	slow := func() (release chan error, done chan error) {
+		release, done = make(chan error), make(chan error, 1)
+		ctx := context.WithValue(context.Background(), releaseKey{}, release)
+		go func() { done <- handler(ctx, testEvent{Name: "slow"}) }()
+		<-entered
+		return release, done
+	}
+
+	// A slow call admitted while closed is still running when the circuit
+	// opens and a trial is admitted.
+	releaseStale, stale := slow()
+	_ = handler(context.Background(), testEvent{})
+	clock.Advance(time.Second)
+	releaseTrial, trial := slow()
+
+	releaseStale <- nil
+	if err := <-stale; err != nil {
+		t.Fatalf("unexpected error from the stale call: %v", err)
+	}
+	typ := reflect.TypeOf(testEvent{})
+	if breaker.State(typ) != typemux.CircuitHalfOpen {
+		t.Errorf("expected a stale success to leave the circuit half-open, got %v", breaker.State(typ))
+	}
+	if err := handler(context.Background(), testEvent{}); !errors.Is(err, typemux.ErrCircuitOpen) {
+		t.Errorf("expected calls rejected while the trial runs, got %v", err)
+	}
+
+	releaseTrial <- errDeadlock
+	if err := <-trial; !errors.Is(err, errDeadlock) {
+		t.Fatalf("expected trial error, got %v", err)
+	}
+	if breaker.State(typ) != typemux.CircuitOpen {
+		t.Errorf("expected the failed trial to reopen the circuit, got %v", breaker.State(typ))
+	}
+}
+
+func TestCircuitBreaker_PanicIsFailure(t *testing.T) {
+	clock := newFakeClock()
+	breaker := typemux.NewCircuitBreaker(typemux.BreakerConfig{
+		FailureThreshold: 1,
+		Cooldown:         time.Second,
+		Clock:            clock,
+	})
+
+	var panics bool
+	reg := typemux.NewRegistry()
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error {
+		if panics {
+			panic("boom")
+		}
+		return errDeadlock
+	})
+	dispatch := func() error {
+		return typemux.Dispatch(reg, context.Background(), testEvent{},
+			typemux.RecoverDispatch(typemux.RecoverConfig{}), breaker.Middleware())
+	}
+
+	_ = dispatch()
+	clock.Advance(time.Second)
+
+	// The half-open trial panics: the circuit reopens instead of waiting for
+	// a trial that never finishes.
+	panics = true
+	if err := dispatch(); !errors.As(err, new(*typemux.PanicError)) {
+		t.Fatalf("expected *PanicError, got %v", err)
+	}
+	typ := reflect.TypeOf(testEvent{})
+	if breaker.State(typ) != typemux.CircuitOpen {
+		t.Errorf("expected open circuit after a panicking trial, got %v", breaker.State(typ))
+	}
+
+	panics = false
+	clock.Advance(time.Second)
+	if err := dispatch(); !errors.Is(err, errDeadlock) {
+		t.Errorf("expected a new trial after the cooldown, got %v", err)
+	}
+}
+
+func TestCircuitBreaker_IsFailure(t *testing.T) {
+	breaker := typemux.NewCircuitBreaker(typemux.BreakerConfig{
+		FailureThreshold: 1,
+		IsFailure:        func(err error) bool { return errors.Is(err, errDeadlock) },
+	})
+	handler := typemux.CircuitFor[testEvent](breaker)(func(ctx context.Context, e testEvent) error {
+		return permanentError{}
+	})
+
+	for range 3 {
+		if err := handler(context.Background(), testEvent{}); !errors.As(err, new(permanentError)) {
+			t.Fatalf("expected handler error, got %v", err)
+		}
+	}
+	if got := breaker.State(reflect.TypeOf(testEvent{})); got != typemux.CircuitClosed {
+		t.Errorf("expected errors not counted as failures to keep the circuit closed, got %v", got)
+	}
+}
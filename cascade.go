@@ -0,0 +1,174 @@
+package typemux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrNoCascade is returned by Emit, and so by emitting handlers, when values
+// are emitted outside DispatchCascade — where they would otherwise be lost.
+var ErrNoCascade = errors.New("emit outside cascade")
+
+// ErrCascadeDepth is returned by DispatchCascade when emitted values nest
+// deeper than CascadeConfig.MaxDepth, which usually means a cycle.
+var ErrCascadeDepth = errors.New("cascade depth exceeded")
+
+// DefaultCascadeDepth is the MaxDepth used when CascadeConfig leaves it zero.
+const DefaultCascadeDepth = 32
+
+// EmittingHandlerFunc is a handler that returns follow-up values to dispatch
+// once it succeeds.
+type EmittingHandlerFunc[T any] func(ctx context.Context, val T) ([]any, error)
+
+// CascadeOrder controls the order in which DispatchCascade dispatches
+// emitted values.
+type CascadeOrder int
+
+const (
+	// CascadeBreadthFirst dispatches all values emitted at one depth before
+	// any value they emit in turn.
+	CascadeBreadthFirst CascadeOrder = iota
+	// CascadeDepthFirst dispatches the values emitted by a handler right
+	// after it, before its siblings.
+	CascadeDepthFirst
+)
+
+// CascadeConfig configures DispatchCascade.
+type CascadeConfig struct {
+	// Order decides between breadth-first and depth-first dispatch.
+	Order CascadeOrder
+	// MaxDepth bounds how deeply emitted values may nest; the value given to
+	// DispatchCascade is at depth 0. Defaults to DefaultCascadeDepth.
+	MaxDepth int
+	// Collect stops after the first dispatch: the values it emits are
+	// returned instead of dispatched.
+	Collect bool
+	// Middleware is applied to every Dispatch, outermost-first.
+	Middleware []DispatchMiddleware
+}
+
+type emitterKey struct{}
+
+// emitter buffers the values emitted during one dispatch.
+type emitter struct {
+	mu     sync.Mutex
+	values []any
+}
+
+func (e *emitter) add(values ...any) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.values = append(e.values, values...)
+}
+
+// emitting gives each call of handler inside a cascade its own buffer, added
+// to the dispatch's buffer only if the call succeeds. A handler re-run by
+// middleware such as Retry thus emits the values of its successful call only.
+func emitting[T any](handler HandlerFunc[T]) HandlerFunc[T] {
+	return func(ctx context.Context, val T) error {
+		parent, ok := ctx.Value(emitterKey{}).(*emitter)
+		if !ok {
+			return handler(ctx, val)
+		}
+
+		e := &emitter{}
+		if err := handler(context.WithValue(ctx, emitterKey{}, e), val); err != nil {
+			return err
+		}
+		parent.add(e.values...)
+		return nil
+	}
+}
+
+// Emit queues values to be dispatched by the enclosing DispatchCascade once
+// the current dispatch succeeds; if it fails, the values are discarded. So
+// are the values of a handler call that fails and is retried.
+//
+// Outside a cascade it returns ErrNoCascade, unless values is empty.
+func Emit(ctx context.Context, values ...any) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	e, ok := ctx.Value(emitterKey{}).(*emitter)
+	if !ok {
+		return fmt.Errorf("typemux: %w: %d values emitted", ErrNoCascade, len(values))
+	}
+
+	e.add(values...)
+	return nil
+}
+
+// RegisterEmitting adds a handler for values of type T that emits follow-up
+// values, with optional middleware. The returned values are passed to Emit
+// when the handler succeeds; otherwise it behaves exactly like a handler
+// registered with RegisterDispatch.
+func RegisterEmitting[T any](reg dispatchRegistry, handler EmittingHandlerFunc[T], middleware ...Middleware[T]) Registration {
+	return RegisterDispatch(reg, func(ctx context.Context, val T) error {
+		emitted, err := handler(ctx, val)
+		if err != nil {
+			return err
+		}
+		return Emit(ctx, emitted...)
+	}, middleware...)
+}
+
+type cascadeItem struct {
+	v     any
+	depth int
+}
+
+// DispatchCascade dispatches v and then, in the configured order, every value
+// emitted along the way by handlers through Emit or RegisterEmitting. Values
+// emitted during a failed dispatch are discarded.
+//
+// The cascade stops at the first error, leaving values already dispatched
+// handled, and fails with ErrCascadeDepth when values nest deeper than
+// cfg.MaxDepth.
+//
+// It returns the emitted values in the order they were dispatched, or with
+// cfg.Collect the values emitted by v's handler, undispatched.
+func DispatchCascade(disp dispatcher, ctx context.Context, v any, cfg CascadeConfig) ([]any, error) {
+	maxDepth := cfg.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultCascadeDepth
+	}
+
+	var dispatched []any
+	pending := []cascadeItem{{v: v}}
+	for len(pending) > 0 {
+		item := pending[0]
+		pending = pending[1:]
+
+		if item.depth > maxDepth {
+			return dispatched, fmt.Errorf("typemux: %w: max depth %d at type %T", ErrCascadeDepth, maxDepth, item.v)
+		}
+
+		e := &emitter{}
+		if err := Dispatch(disp, context.WithValue(ctx, emitterKey{}, e), item.v, cfg.Middleware...); err != nil {
+			return dispatched, err
+		}
+		if item.depth > 0 {
+			dispatched = append(dispatched, item.v)
+		}
+
+		if cfg.Collect {
+			return e.values, nil
+		}
+
+		next := make([]cascadeItem, len(e.values))
+		for i, emitted := range e.values {
+			next[i] = cascadeItem{v: emitted, depth: item.depth + 1}
+		}
+		if cfg.Order == CascadeDepthFirst {
+			pending = append(next, pending...)
+		} else {
+			pending = append(pending, next...)
+		}
+	}
+
+	return dispatched, nil
+}
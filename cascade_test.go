@@ -0,0 +1,214 @@
+package typemux_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/struct0x/typemux"
+)
+
+type placeOrder struct{ ID string }
+
+type (
+	orderAccepted struct{ ID string }
+	stockReserved struct{ ID string }
+	invoiceSent   struct{ ID string }
+	customerMail  struct{ ID string }
+)
+
+// newOrderFlow registers handlers forming a small tree:
+//
+//	placeOrder -> orderAccepted -> stockReserved
+//	           -> invoiceSent   -> customerMail
+func newOrderFlow(trace *[]string) *typemux.Registry {
+	reg := typemux.NewRegistry()
+	record := func(v any) { *trace = append(*trace, reflect.TypeOf(v).Name()) }
+
+	typemux.RegisterEmitting(reg, func(ctx context.Context, c placeOrder) ([]any, error) {
+		record(c)
+		return []any{orderAccepted(c), invoiceSent(c)}, nil
+	})
+	typemux.RegisterEmitting(reg, func(ctx context.Context, e orderAccepted) ([]any, error) {
+		record(e)
+		return []any{stockReserved(e)}, nil
+	})
+	// Handlers can also emit through the context.
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e invoiceSent) error {
+		record(e)
+		return typemux.Emit(ctx, customerMail(e))
+	})
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e stockReserved) error {
+		record(e)
+		return nil
+	})
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e customerMail) error {
+		record(e)
+		return nil
+	})
+	return reg
+}
+
+func TestDispatchCascade_Order(t *testing.T) {
+	tests := []struct {
+		name     string
+		order    typemux.CascadeOrder
+		expected []string
+	}{
+		{
+			name:     "breadth_first",
+			order:    typemux.CascadeBreadthFirst,
+			expected: []string{"placeOrder", "orderAccepted", "invoiceSent", "stockReserved", "customerMail"},
+		},
+		{
+			name:     "depth_first",
+			order:    typemux.CascadeDepthFirst,
+			expected: []string{"placeOrder", "orderAccepted", "stockReserved", "invoiceSent", "customerMail"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var trace []string
+			reg := newOrderFlow(&trace)
+
+			dispatched, err := typemux.DispatchCascade(reg.Seal(), context.Background(), placeOrder{ID: "o1"}, typemux.CascadeConfig{Order: tt.order})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(trace, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, trace)
+			}
+			if len(dispatched) != 4 {
+				t.Errorf("expected 4 emitted values dispatched, got %v", dispatched)
+			}
+		})
+	}
+}
+
+func TestDispatchCascade_Collect(t *testing.T) {
+	var trace []string
+	reg := newOrderFlow(&trace)
+
+	emitted, err := typemux.DispatchCascade(reg, context.Background(), placeOrder{ID: "o1"}, typemux.CascadeConfig{Collect: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []any{orderAccepted{ID: "o1"}, invoiceSent{ID: "o1"}}
+	if !reflect.DeepEqual(emitted, want) {
+		t.Errorf("expected %v, got %v", want, emitted)
+	}
+	if len(trace) != 1 {
+		t.Errorf("expected only the root to be dispatched, got %v", trace)
+	}
+}
+
+type ping struct{ N int }
+
+func TestDispatchCascade_MaxDepth(t *testing.T) {
+	reg := typemux.NewRegistry()
+	var calls int
+	typemux.RegisterEmitting(reg, func(ctx context.Context, p ping) ([]any, error) {
+		calls++
+		return []any{ping{N: p.N + 1}}, nil
+	})
+
+	_, err := typemux.DispatchCascade(reg, context.Background(), ping{}, typemux.CascadeConfig{MaxDepth: 3})
+	if !errors.Is(err, typemux.ErrCascadeDepth) {
+		t.Fatalf("expected ErrCascadeDepth, got %v", err)
+	}
+	const want = "typemux: cascade depth exceeded: max depth 3 at type typemux_test.ping"
+	if err.Error() != want {
+		t.Errorf("expected %q, got %q", want, err.Error())
+	}
+	if calls != 4 {
+		t.Errorf("expected depths 0 through 3 to run, got %d calls", calls)
+	}
+}
+
+func TestDispatchCascade_FailedDispatchDiscardsEmissions(t *testing.T) {
+	errRejected := errors.New("rejected")
+	reg := typemux.NewRegistry()
+	typemux.RegisterDispatch(reg, func(ctx context.Context, c placeOrder) error {
+		if err := typemux.Emit(ctx, orderAccepted(c)); err != nil {
+			return err
+		}
+		return errRejected
+	})
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e orderAccepted) error {
+		t.Error("values emitted by a failed dispatch must not be dispatched")
+		return nil
+	})
+
+	dispatched, err := typemux.DispatchCascade(reg, context.Background(), placeOrder{}, typemux.CascadeConfig{})
+	if !errors.Is(err, errRejected) {
+		t.Fatalf("expected handler error, got %v", err)
+	}
+	if len(dispatched) != 0 {
+		t.Errorf("expected nothing dispatched, got %v", dispatched)
+	}
+}
+
+func TestDispatchCascade_RetriedHandlerEmitsOnce(t *testing.T) {
+	tests := map[string]struct {
+		typed    []typemux.Middleware[placeOrder]
+		dispatch []typemux.DispatchMiddleware
+	}{
+		"typed_retry": {
+			typed: []typemux.Middleware[placeOrder]{typemux.Retry[placeOrder](typemux.RetryConfig{Clock: newFakeClock()})},
+		},
+		"dispatch_retry": {
+			dispatch: []typemux.DispatchMiddleware{typemux.RetryDispatch(typemux.RetryConfig{Clock: newFakeClock()})},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			reg := typemux.NewRegistry()
+			var attempts, accepted int
+			typemux.RegisterDispatch(reg, func(ctx context.Context, c placeOrder) error {
+				attempts++
+				if err := typemux.Emit(ctx, orderAccepted(c)); err != nil {
+					return err
+				}
+				if attempts == 1 {
+					return errDeadlock
+				}
+				return nil
+			}, tt.typed...)
+			typemux.RegisterDispatch(reg, func(ctx context.Context, e orderAccepted) error {
+				accepted++
+				return nil
+			})
+
+			dispatched, err := typemux.DispatchCascade(reg, context.Background(), placeOrder{ID: "o1"},
+				typemux.CascadeConfig{Middleware: tt.dispatch})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if attempts != 2 || accepted != 1 {
+				t.Errorf("expected 2 attempts and 1 follow-up, got %d and %d", attempts, accepted)
+			}
+			if want := []any{orderAccepted{ID: "o1"}}; !reflect.DeepEqual(dispatched, want) {
+				t.Errorf("expected %v dispatched, got %v", want, dispatched)
+			}
+		})
+	}
+}
+
+func TestEmit_OutsideCascade(t *testing.T) {
+	var trace []string
+	reg := newOrderFlow(&trace)
+
+	err := typemux.Dispatch(reg, context.Background(), placeOrder{ID: "o1"})
+	if !errors.Is(err, typemux.ErrNoCascade) {
+		t.Fatalf("expected ErrNoCascade, got %v", err)
+	}
+
+	// Emitting nothing is fine anywhere.
+	if err := typemux.Emit(context.Background()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
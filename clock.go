@@ -0,0 +1,26 @@
+package typemux
+
+import "time"
+
+// Clock is the source of time for middleware that waits or measures, such as
+// Retry. Inject a fake in tests to run without real sleeps.
+type Clock interface {
+	Now() time.Time
+	// After returns a channel that receives the current time once d has
+	// elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// clockOrSystem returns c, or the system clock if c is nil.
+func clockOrSystem(c Clock) Clock {
+	if c == nil {
+		return systemClock{}
+	}
+	return c
+}
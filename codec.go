@@ -1,11 +1,14 @@
 package typemux
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"maps"
 	"reflect"
+	"slices"
 	"sync"
 )
 
@@ -72,6 +75,55 @@ func Unsupported[X, Y any](X) (Y, error) {
 	return zero, ErrUnsupported
 }
 
+// CodecCall describes a call into one half of a codec.
+type CodecCall struct {
+	Key       any
+	Type      reflect.Type
+	DataType  reflect.Type
+	Direction CodecDirection
+}
+
+// CodecMiddleware wraps a call into one half of a codec. in is the data being
+// unmarshaled or the value being marshaled; next returns the value or the
+// data it produces. ctx is the one given to CreateTypeContext or
+// SerializeContext, or context.Background(). Its errors reach the caller of
+// CreateType or Serialize as a *CodecError.
+type CodecMiddleware func(ctx context.Context, call CodecCall, in any, next func(ctx context.Context, in any) (any, error)) (any, error)
+
+// WithCodecMiddleware wraps both halves of every codec registered with
+// RegisterCodec in middleware, applied outermost first. Repeated options
+// append. Sealed and merged copies of a codec keep the middleware it was
+// registered with; children inherit the option.
+func WithCodecMiddleware(middleware ...CodecMiddleware) Option {
+	return func(o *options) {
+		o.codecMW = append(slices.Clip(o.codecMW), middleware...)
+	}
+}
+
+// wrapCodec wraps fn, one half of the codec described by call, in the codec
+// middleware configured by the options.
+func (o *options) wrapCodec(call CodecCall, fn func(context.Context, any) (any, error)) func(context.Context, any) (any, error) {
+	for i := len(o.codecMW) - 1; i >= 0; i-- {
+		mw, next := o.codecMW[i], fn
+		fn = func(ctx context.Context, in any) (any, error) {
+			return mw(ctx, call, in, next)
+		}
+	}
+	return fn
+}
+
+// codecErrors reports the errors of fn, the unmarshal half of the codec
+// described by call wrapped in its middleware, as *CodecError.
+func codecErrors(call CodecCall, fn func(context.Context, any) (any, error)) factoryFuncAny {
+	return func(ctx context.Context, data any) (any, error) {
+		v, err := fn(ctx, data)
+		if err != nil {
+			return v, &CodecError{Key: call.Key, Type: call.Type, DataType: call.DataType, Direction: call.Direction, Err: err}
+		}
+		return v, nil
+	}
+}
+
 // CodecRegistry holds registered codecs. Both halves are heterogeneous over
 // DATA — a single CodecRegistry can hold codecs producing/consuming
 // different DATA types simultaneously. The DATA type is chosen at each
@@ -80,107 +132,251 @@ func Unsupported[X, Y any](X) (Y, error) {
 // The factory side is keyed by (key, DATA-type); the serializer side by
 // (T, DATA-type). Use NewCodecRegistry() to create one, then RegisterCodec().
 type CodecRegistry struct {
+	opts   options
+	parent *CodecRegistry
+
 	mu          sync.RWMutex
 	factories   map[any]map[reflect.Type]factoryFuncAny
 	serializers map[reflect.Type]map[reflect.Type]serializerEntry
+
+	// Registration ownership per slot, so a stale Registration can't remove
+	// a replacement.
+	factoryIDs    map[factorySlot]uint64
+	serializerIDs map[serializerSlot]uint64
+	nextID        uint64
+}
+
+type factorySlot struct {
+	key      any
+	dataType reflect.Type
+}
+
+type serializerSlot struct {
+	typ      reflect.Type
+	dataType reflect.Type
 }
 
 // NewCodecRegistry creates a new empty CodecRegistry.
-func NewCodecRegistry() *CodecRegistry {
+func NewCodecRegistry(opts ...Option) *CodecRegistry {
 	return &CodecRegistry{
-		factories:   make(map[any]map[reflect.Type]factoryFuncAny),
-		serializers: make(map[reflect.Type]map[reflect.Type]serializerEntry),
+		opts:          newOptions(opts),
+		factories:     make(map[any]map[reflect.Type]factoryFuncAny),
+		serializers:   make(map[reflect.Type]map[reflect.Type]serializerEntry),
+		factoryIDs:    make(map[factorySlot]uint64),
+		serializerIDs: make(map[serializerSlot]uint64),
 	}
 }
 
-func (r *CodecRegistry) registerFactory(key any, dataType reflect.Type, factory factoryFuncAny) {
+func (r *CodecRegistry) registerCodec(key any, typ, dataType reflect.Type, factory factoryFuncAny, entry serializerEntry) (unregister func() bool) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	fslot := factorySlot{key: key, dataType: dataType}
+	sslot := serializerSlot{typ: typ, dataType: dataType}
+
+	// Check both halves before touching either, so a strict panic never
+	// leaves a half-registered codec behind.
+	if r.opts.strict {
+		if _, ok := r.factoryIDs[fslot]; ok {
+			panic(fmt.Errorf("typemux: %w: codec for key %v and data %v", ErrDuplicateRegistration, key, dataType))
+		}
+		if _, ok := r.serializerIDs[sslot]; ok {
+			panic(fmt.Errorf("typemux: %w: codec for type %v and data %v", ErrDuplicateRegistration, typ, dataType))
+		}
+	}
+
+	_, replacedFactory := r.factoryIDs[fslot]
+	_, replacedSerializer := r.serializerIDs[sslot]
+	r.opts.logRegistration("codec", replacedFactory || replacedSerializer,
+		slog.Any("key", key), slog.String("type", typeName(typ)), slog.String("data_type", typeName(dataType)))
+
+	unmarshal := CodecCall{Key: key, Type: typ, DataType: dataType, Direction: CodecUnmarshal}
+	factory = codecErrors(unmarshal, r.opts.wrapCodec(unmarshal, factory))
+	entry.fn = serializerFuncAny(r.opts.wrapCodec(CodecCall{Key: key, Type: typ, DataType: dataType, Direction: CodecMarshal}, entry.fn))
+
+	fid := r.setFactory(fslot, factory)
+	sid := r.setSerializer(sslot, entry)
+
+	return func() bool {
+		removedFactory := r.unregisterFactory(fslot, fid)
+		removedSerializer := r.unregisterSerializer(sslot, sid)
+		return removedFactory || removedSerializer
+	}
+}
+
+func (r *CodecRegistry) setFactory(slot factorySlot, factory factoryFuncAny) (id uint64) {
 	if r.factories == nil {
 		r.factories = make(map[any]map[reflect.Type]factoryFuncAny)
 	}
+	if r.factoryIDs == nil {
+		r.factoryIDs = make(map[factorySlot]uint64)
+	}
 
-	inner, ok := r.factories[key]
+	inner, ok := r.factories[slot.key]
 	if !ok {
 		inner = make(map[reflect.Type]factoryFuncAny)
-		r.factories[key] = inner
+		r.factories[slot.key] = inner
 	}
-	inner[dataType] = factory
-}
+	inner[slot.dataType] = factory
 
-func (r *CodecRegistry) registerSerializer(typ, dataType reflect.Type, entry serializerEntry) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+	r.nextID++
+	r.factoryIDs[slot] = r.nextID
+	return r.nextID
+}
 
+func (r *CodecRegistry) setSerializer(slot serializerSlot, entry serializerEntry) (id uint64) {
 	if r.serializers == nil {
 		r.serializers = make(map[reflect.Type]map[reflect.Type]serializerEntry)
 	}
+	if r.serializerIDs == nil {
+		r.serializerIDs = make(map[serializerSlot]uint64)
+	}
 
-	inner, ok := r.serializers[typ]
+	inner, ok := r.serializers[slot.typ]
 	if !ok {
 		inner = make(map[reflect.Type]serializerEntry)
-		r.serializers[typ] = inner
+		r.serializers[slot.typ] = inner
 	}
-	inner[dataType] = entry
+	inner[slot.dataType] = entry
+
+	r.nextID++
+	r.serializerIDs[slot] = r.nextID
+	return r.nextID
 }
 
-func (r *CodecRegistry) getFactory(key any, dataType reflect.Type) (factoryFuncAny, bool) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+func (r *CodecRegistry) unregisterFactory(slot factorySlot, id uint64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	inner, ok := r.factories[key]
-	if !ok {
-		return nil, false
+	if r.factoryIDs[slot] != id {
+		return false
 	}
-	f, ok := inner[dataType]
-	return f, ok
+
+	delete(r.factoryIDs, slot)
+	inner := r.factories[slot.key]
+	delete(inner, slot.dataType)
+	if len(inner) == 0 {
+		delete(r.factories, slot.key)
+	}
+	return true
+}
+
+func (r *CodecRegistry) unregisterSerializer(slot serializerSlot, id uint64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.serializerIDs[slot] != id {
+		return false
+	}
+
+	delete(r.serializerIDs, slot)
+	inner := r.serializers[slot.typ]
+	delete(inner, slot.dataType)
+	if len(inner) == 0 {
+		delete(r.serializers, slot.typ)
+	}
+	return true
+}
+
+// Child creates an empty CodecRegistry whose lookups fall back to r for any
+// (key, DATA) factory or (T, DATA) serializer it doesn't register itself. The
+// child inherits r's options.
+//
+// Later registrations on r are visible through the child. Sealing the child
+// flattens the chain into one immutable registry, so lookup cost doesn't grow
+// with depth.
+func (r *CodecRegistry) Child() *CodecRegistry {
+	child := NewCodecRegistry()
+	child.opts = r.opts
+	child.parent = r
+	return child
+}
+
+// inheritCodec walks r and its ancestors, nearest first, and returns the
+// first entry get finds. Each registry is read under its own lock.
+func inheritCodec[X any](r *CodecRegistry, get func(*CodecRegistry) (X, bool)) (X, bool) {
+	for reg := r; reg != nil; reg = reg.parent {
+		reg.mu.RLock()
+		x, ok := get(reg)
+		reg.mu.RUnlock()
+		if ok {
+			return x, true
+		}
+	}
+
+	var zero X
+	return zero, false
+}
+
+func (r *CodecRegistry) getFactory(key any, dataType reflect.Type) (factoryFuncAny, bool) {
+	return inheritCodec(r, func(reg *CodecRegistry) (factoryFuncAny, bool) {
+		f, ok := reg.factories[key][dataType]
+		return f, ok
+	})
 }
 
 func (r *CodecRegistry) keyRegistered(key any) bool {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	_, ok := r.factories[key]
+	_, ok := inheritCodec(r, func(reg *CodecRegistry) (struct{}, bool) {
+		_, ok := reg.factories[key]
+		return struct{}{}, ok
+	})
 	return ok
 }
 
 func (r *CodecRegistry) getSerializer(typ, dataType reflect.Type) (serializerEntry, bool) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	return inheritCodec(r, func(reg *CodecRegistry) (serializerEntry, bool) {
+		e, ok := reg.serializers[typ][dataType]
+		return e, ok
+	})
+}
 
-	inner, ok := r.serializers[typ]
-	if !ok {
-		return serializerEntry{}, false
-	}
-	e, ok := inner[dataType]
-	return e, ok
+func (r *CodecRegistry) pointerPolicy() PointerPolicy {
+	return r.opts.pointers
 }
 
 func (r *CodecRegistry) typeRegistered(typ reflect.Type) bool {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	_, ok := r.serializers[typ]
+	_, ok := inheritCodec(r, func(reg *CodecRegistry) (struct{}, bool) {
+		_, ok := reg.serializers[typ]
+		return struct{}{}, ok
+	})
 	return ok
 }
 
 // Seal finalizes the CodecRegistry and returns a SealedCodecRegistry.
+//
+// For a child registry, the codecs of all its ancestors are flattened into
+// the result, the nearest registry winning for each (key, DATA) and (T, DATA).
 func (r *CodecRegistry) Seal() *SealedCodecRegistry {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	factories := make(map[any]map[reflect.Type]factoryFuncAny, len(r.factories))
-	for k, inner := range r.factories {
-		factories[k] = maps.Clone(inner)
+	var lineage []*CodecRegistry
+	for reg := r; reg != nil; reg = reg.parent {
+		lineage = append(lineage, reg)
 	}
-	serializers := make(map[reflect.Type]map[reflect.Type]serializerEntry, len(r.serializers))
-	for t, inner := range r.serializers {
-		serializers[t] = maps.Clone(inner)
+	slices.Reverse(lineage)
+
+	factories := make(map[any]map[reflect.Type]factoryFuncAny)
+	serializers := make(map[reflect.Type]map[reflect.Type]serializerEntry)
+	for _, reg := range lineage {
+		reg.mu.RLock()
+		for k, inner := range reg.factories {
+			if factories[k] == nil {
+				factories[k] = make(map[reflect.Type]factoryFuncAny, len(inner))
+			}
+			maps.Copy(factories[k], inner)
+		}
+		for t, inner := range reg.serializers {
+			if serializers[t] == nil {
+				serializers[t] = make(map[reflect.Type]serializerEntry, len(inner))
+			}
+			maps.Copy(serializers[t], inner)
+		}
+		reg.mu.RUnlock()
 	}
-	return &SealedCodecRegistry{factories: factories, serializers: serializers}
+	return &SealedCodecRegistry{pointers: r.opts.pointers, factories: factories, serializers: serializers}
 }
 
 // SealedCodecRegistry is an immutable codec resolver.
 type SealedCodecRegistry struct {
+	pointers    PointerPolicy
 	factories   map[any]map[reflect.Type]factoryFuncAny
 	serializers map[reflect.Type]map[reflect.Type]serializerEntry
 }
@@ -208,6 +404,10 @@ func (s *SealedCodecRegistry) getSerializer(typ, dataType reflect.Type) (seriali
 	return e, ok
 }
 
+func (s *SealedCodecRegistry) pointerPolicy() PointerPolicy {
+	return s.pointers
+}
+
 func (s *SealedCodecRegistry) typeRegistered(typ reflect.Type) bool {
 	_, ok := s.serializers[typ]
 	return ok
@@ -216,8 +416,7 @@ func (s *SealedCodecRegistry) typeRegistered(typ reflect.Type) bool {
 // codecRegistrar is the interface satisfied by *CodecRegistry and *Registry,
 // letting RegisterCodec write into either.
 type codecRegistrar interface {
-	registerFactory(key any, dataType reflect.Type, factory factoryFuncAny)
-	registerSerializer(typ, dataType reflect.Type, entry serializerEntry)
+	registerCodec(key any, typ, dataType reflect.Type, factory factoryFuncAny, entry serializerEntry) (unregister func() bool)
 }
 
 // RegisterCodec registers both halves of a codec for type T over wire format
@@ -229,12 +428,14 @@ type codecRegistrar interface {
 // Registering with the same (key, DATA) or (T, DATA) replaces the prior one.
 //
 // The key can be any comparable type (string, int, custom enum, etc.).
-func RegisterCodec[KEY comparable, DATA, T any](reg codecRegistrar, key KEY, codec Codec[DATA, T]) {
+//
+// The returned Registration removes both halves again; it may be ignored.
+func RegisterCodec[KEY comparable, DATA, T any](reg codecRegistrar, key KEY, codec Codec[DATA, T]) Registration {
 	dataType := reflect.TypeOf((*DATA)(nil)).Elem()
 	typ := reflect.TypeOf((*T)(nil)).Elem()
 
 	unmarshal := codec.Unmarshal
-	reg.registerFactory(key, dataType, func(data any) (any, error) {
+	factory := func(ctx context.Context, data any) (any, error) {
 		d, ok := data.(DATA)
 		if !ok {
 			// Unreachable via CreateType — lookup enforces the DATA match.
@@ -242,12 +443,12 @@ func RegisterCodec[KEY comparable, DATA, T any](reg codecRegistrar, key KEY, cod
 			return nil, fmt.Errorf("typemux: %w: %T, got %T", ErrDataTypeNotSupported, zero, data)
 		}
 		return unmarshal(d)
-	})
+	}
 
 	marshal := codec.Marshal
-	reg.registerSerializer(typ, dataType, serializerEntry{
+	serializer := serializerEntry{
 		key: key,
-		fn: func(v any) (any, error) {
+		fn: func(ctx context.Context, v any) (any, error) {
 			tv, ok := v.(T)
 			if !ok {
 				var zero T
@@ -255,5 +456,7 @@ func RegisterCodec[KEY comparable, DATA, T any](reg codecRegistrar, key KEY, cod
 			}
 			return marshal(tv)
 		},
-	})
+	}
+
+	return Registration{unregister: reg.registerCodec(key, typ, dataType, factory, serializer)}
 }
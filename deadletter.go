@@ -0,0 +1,338 @@
+package typemux
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DeadLetter records a value whose dispatch failed, in its serialized form.
+type DeadLetter[KEY comparable, DATA any] struct {
+	// ID identifies the letter within its sink.
+	ID string `json:"id"`
+	// Key and Data are the value as produced by Serialize.
+	Key  KEY  `json:"key"`
+	Data DATA `json:"data"`
+	// Type is the Go type of the value, for inspection.
+	Type string `json:"type"`
+	// Error is the message of the last failure.
+	Error string `json:"error"`
+	// Attempts counts the dispatch attempts made so far, retries included.
+	Attempts int `json:"attempts"`
+	// FirstFailed and LastFailed are the times of the first and the latest
+	// failure.
+	FirstFailed time.Time `json:"first_failed"`
+	LastFailed  time.Time `json:"last_failed"`
+}
+
+// DeadLetterSink stores dead letters.
+type DeadLetterSink[KEY comparable, DATA any] interface {
+	// Put stores letter, replacing any letter with the same ID.
+	Put(ctx context.Context, letter DeadLetter[KEY, DATA]) error
+	// Letters returns the stored letters in the order they were first put.
+	Letters(ctx context.Context) ([]DeadLetter[KEY, DATA], error)
+	// Delete removes the letter with the given ID, if any.
+	Delete(ctx context.Context, id string) error
+}
+
+// DeadLetterConfig configures DeadLetterDispatch.
+type DeadLetterConfig struct {
+	// Skip, if set, selects errors that are returned without dead-lettering
+	// the value, e.g. validation failures that replaying can't fix.
+	Skip func(err error) bool
+	// Clock timestamps the failures. Defaults to the system clock.
+	Clock Clock
+}
+
+// DeadLetterDispatch returns a DispatchMiddleware that captures values whose
+// dispatch failed into sink, serialized through reg as (KEY, DATA). Place it
+// outside Retry: the attempt count is taken from a *RetryExhaustedError.
+//
+// The dispatch error is still returned. If the value can't be serialized or
+// stored, that error is joined to it. Dispatches made by Replay are passed
+// through: Replay updates the replayed letter itself.
+func DeadLetterDispatch[KEY comparable, DATA any](reg serializerResolver, sink DeadLetterSink[KEY, DATA], cfg DeadLetterConfig) DispatchMiddleware {
+	clock := clockOrSystem(cfg.Clock)
+
+	return func(ctx context.Context, event any, next func(context.Context) error) error {
+		if replaying, _ := ctx.Value(replayKey{}).(bool); replaying {
+			return next(ctx)
+		}
+
+		err := next(ctx)
+		if err == nil || (cfg.Skip != nil && cfg.Skip(err)) {
+			return err
+		}
+
+		key, data, serr := Serialize[KEY, DATA](reg, event)
+		if serr != nil {
+			return errors.Join(err, fmt.Errorf("typemux: dead-letter %T: %w", event, serr))
+		}
+
+		now := clock.Now()
+		letter := DeadLetter[KEY, DATA]{
+			ID:          newLetterID(),
+			Key:         key,
+			Data:        data,
+			Type:        fmt.Sprintf("%T", event),
+			Error:       err.Error(),
+			Attempts:    attempts(err),
+			FirstFailed: now,
+			LastFailed:  now,
+		}
+		if perr := sink.Put(ctx, letter); perr != nil {
+			return errors.Join(err, fmt.Errorf("typemux: dead-letter %T: %w", event, perr))
+		}
+		return err
+	}
+}
+
+// replayer is satisfied by Registry and SealedRegistry.
+type replayer interface {
+	factoryResolver
+	dispatcher
+}
+
+// replayKey marks the context Replay passes to ReplayConfig.Middleware.
+type replayKey struct{}
+
+// ReplayConfig configures Replay.
+type ReplayConfig struct {
+	// Middleware is applied to every Dispatch, outermost-first. Any
+	// DeadLetterDispatch in it is skipped, so a failing replay doesn't store
+	// a second letter; handlers and their nested dispatches are unaffected.
+	Middleware []DispatchMiddleware
+	// Clock timestamps repeated failures. Defaults to the system clock.
+	Clock Clock
+}
+
+// Replay feeds every letter in sink back through CreateType and Dispatch.
+// Letters dispatched successfully are deleted; letters failing again stay,
+// with their error, attempt count and LastFailed updated. A letter that
+// can't be decoded keeps its attempt count, as nothing was dispatched.
+//
+// It returns the number of letters replayed and deleted, and the errors of
+// the others, joined.
+func Replay[KEY comparable, DATA any](ctx context.Context, reg replayer, sink DeadLetterSink[KEY, DATA], cfg ReplayConfig) (int, error) {
+	clock := clockOrSystem(cfg.Clock)
+
+	// The replay mark is visible to cfg.Middleware only: the innermost
+	// middleware removes it before the handler runs.
+	mw := append(slices.Clip(cfg.Middleware), func(ctx context.Context, event any, next func(context.Context) error) error {
+		return next(context.WithValue(ctx, replayKey{}, false))
+	})
+	replayCtx := context.WithValue(ctx, replayKey{}, true)
+
+	letters, err := sink.Letters(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		replayed int
+		errs     []error
+	)
+	for _, letter := range letters {
+		if err := ctx.Err(); err != nil {
+			return replayed, errors.Join(append(errs, err)...)
+		}
+
+		v, err := CreateType(reg, letter.Key, letter.Data)
+		dispatched := err == nil
+		if dispatched {
+			err = Dispatch(reg, replayCtx, v, mw...)
+		}
+		if err == nil {
+			if err = sink.Delete(ctx, letter.ID); err == nil {
+				replayed++
+			}
+		} else {
+			letter.Error = err.Error()
+			if dispatched {
+				letter.Attempts += attempts(err)
+			}
+			letter.LastFailed = clock.Now()
+			err = errors.Join(err, sink.Put(ctx, letter))
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("typemux: replay %s: %w", letter.ID, err))
+		}
+	}
+
+	return replayed, errors.Join(errs...)
+}
+
+// attempts returns the number of dispatch attempts behind err.
+func attempts(err error) int {
+	var exhausted *RetryExhaustedError
+	if errors.As(err, &exhausted) {
+		return exhausted.Attempts
+	}
+	return 1
+}
+
+func newLetterID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// MemoryDeadLetterSink is an in-memory DeadLetterSink.
+type MemoryDeadLetterSink[KEY comparable, DATA any] struct {
+	mu      sync.Mutex
+	order   []string
+	letters map[string]DeadLetter[KEY, DATA]
+}
+
+// NewMemoryDeadLetterSink creates an empty MemoryDeadLetterSink.
+func NewMemoryDeadLetterSink[KEY comparable, DATA any]() *MemoryDeadLetterSink[KEY, DATA] {
+	return &MemoryDeadLetterSink[KEY, DATA]{letters: make(map[string]DeadLetter[KEY, DATA])}
+}
+
+// Put implements DeadLetterSink.
+func (s *MemoryDeadLetterSink[KEY, DATA]) Put(ctx context.Context, letter DeadLetter[KEY, DATA]) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.put(letter)
+	return nil
+}
+
+// Letters implements DeadLetterSink.
+func (s *MemoryDeadLetterSink[KEY, DATA]) Letters(ctx context.Context) ([]DeadLetter[KEY, DATA], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	letters := make([]DeadLetter[KEY, DATA], len(s.order))
+	for i, id := range s.order {
+		letters[i] = s.letters[id]
+	}
+	return letters, nil
+}
+
+// Delete implements DeadLetterSink.
+func (s *MemoryDeadLetterSink[KEY, DATA]) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.delete(id)
+	return nil
+}
+
+func (s *MemoryDeadLetterSink[KEY, DATA]) put(letter DeadLetter[KEY, DATA]) {
+	if _, ok := s.letters[letter.ID]; !ok {
+		s.order = append(s.order, letter.ID)
+	}
+	s.letters[letter.ID] = letter
+}
+
+func (s *MemoryDeadLetterSink[KEY, DATA]) delete(id string) {
+	if _, ok := s.letters[id]; !ok {
+		return
+	}
+	delete(s.letters, id)
+	s.order = slices.DeleteFunc(s.order, func(o string) bool { return o == id })
+}
+
+// FileDeadLetterSink is a DeadLetterSink persisting letters to a file as a
+// JSON-lines log of puts and deletes, so they survive restarts. KEY and DATA
+// must round-trip through encoding/json. Use NewFileDeadLetterSink to open
+// one and Close to release the file.
+type FileDeadLetterSink[KEY comparable, DATA any] struct {
+	mem *MemoryDeadLetterSink[KEY, DATA]
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+type deadLetterRecord[KEY comparable, DATA any] struct {
+	Put    *DeadLetter[KEY, DATA] `json:"put,omitempty"`
+	Delete string                 `json:"delete,omitempty"`
+}
+
+// NewFileDeadLetterSink opens the sink at path, creating the file if needed
+// and loading the letters it already holds.
+func NewFileDeadLetterSink[KEY comparable, DATA any](path string) (*FileDeadLetterSink[KEY, DATA], error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("typemux: open dead-letter sink: %w", err)
+	}
+
+	mem := NewMemoryDeadLetterSink[KEY, DATA]()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		var rec deadLetterRecord[KEY, DATA]
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("typemux: read dead-letter sink %s: %w", path, err)
+		}
+		if rec.Put != nil {
+			mem.put(*rec.Put)
+		} else {
+			mem.delete(rec.Delete)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("typemux: read dead-letter sink %s: %w", path, err)
+	}
+
+	return &FileDeadLetterSink[KEY, DATA]{mem: mem, f: f}, nil
+}
+
+// Put implements DeadLetterSink. The letter is appended to the file and
+// synced before Put returns.
+func (s *FileDeadLetterSink[KEY, DATA]) Put(ctx context.Context, letter DeadLetter[KEY, DATA]) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.append(deadLetterRecord[KEY, DATA]{Put: &letter}); err != nil {
+		return err
+	}
+	return s.mem.Put(ctx, letter)
+}
+
+// Letters implements DeadLetterSink.
+func (s *FileDeadLetterSink[KEY, DATA]) Letters(ctx context.Context) ([]DeadLetter[KEY, DATA], error) {
+	return s.mem.Letters(ctx)
+}
+
+// Delete implements DeadLetterSink.
+func (s *FileDeadLetterSink[KEY, DATA]) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.append(deadLetterRecord[KEY, DATA]{Delete: id}); err != nil {
+		return err
+	}
+	return s.mem.Delete(ctx, id)
+}
+
+// Close closes the underlying file.
+func (s *FileDeadLetterSink[KEY, DATA]) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.f.Close()
+}
+
+func (s *FileDeadLetterSink[KEY, DATA]) append(rec deadLetterRecord[KEY, DATA]) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := s.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return s.f.Sync()
+}
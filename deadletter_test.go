@@ -0,0 +1,213 @@
+package typemux_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/struct0x/typemux"
+)
+
+// newFailingOrders registers a JSON codec for OrderPlaced and a handler
+// failing with errDeadlock while *fail is set.
+func newFailingOrders(fail *bool, handled *[]string) *typemux.Registry {
+	reg := typemux.NewRegistry()
+	typemux.RegisterCodec(reg, "order_placed", typemux.JSONCodec[OrderPlaced]())
+	typemux.RegisterDispatch(reg, func(ctx context.Context, o OrderPlaced) error {
+		if *fail {
+			return errDeadlock
+		}
+		*handled = append(*handled, o.OrderID)
+		return nil
+	})
+	return reg
+}
+
+func TestDeadLetter_CaptureAndReplay(t *testing.T) {
+	clock := newFakeClock()
+	fail := true
+	var handled []string
+	reg := newFailingOrders(&fail, &handled)
+	sink := typemux.NewMemoryDeadLetterSink[string, []byte]()
+
+	dlq := typemux.DeadLetterDispatch[string, []byte](reg, sink, typemux.DeadLetterConfig{Clock: clock})
+	retry := typemux.RetryDispatch(typemux.RetryConfig{MaxAttempts: 2, Clock: clock})
+
+	err := typemux.Dispatch(reg, context.Background(), OrderPlaced{OrderID: "o1", Amount: 5}, dlq, retry)
+	if !errors.Is(err, errDeadlock) {
+		t.Fatalf("expected the dispatch error, got %v", err)
+	}
+
+	letters, _ := sink.Letters(context.Background())
+	if len(letters) != 1 {
+		t.Fatalf("expected 1 letter, got %d", len(letters))
+	}
+	l := letters[0]
+	if l.Key != "order_placed" || string(l.Data) != `{"order_id":"o1","amount":5}` {
+		t.Errorf("expected the serialized value, got %q %s", l.Key, l.Data)
+	}
+	if l.Type != "typemux_test.OrderPlaced" || l.Attempts != 2 || l.Error != err.Error() {
+		t.Errorf("unexpected letter: %+v", l)
+	}
+	if l.FirstFailed.IsZero() || !l.FirstFailed.Equal(l.LastFailed) {
+		t.Errorf("expected both timestamps set to the failure, got %v and %v", l.FirstFailed, l.LastFailed)
+	}
+
+	// A replay that fails again updates the letter.
+	clock.Advance(time.Hour)
+	n, err := typemux.Replay(context.Background(), reg.Seal(), sink, typemux.ReplayConfig{Clock: clock})
+	if n != 0 || !errors.Is(err, errDeadlock) {
+		t.Fatalf("expected the replay to fail, got %d, %v", n, err)
+	}
+	letters, _ = sink.Letters(context.Background())
+	if len(letters) != 1 || letters[0].Attempts != 3 || !letters[0].LastFailed.After(l.FirstFailed) {
+		t.Errorf("expected the letter updated, got %+v", letters)
+	}
+
+	fail = false
+	n, err = typemux.Replay(context.Background(), reg, sink, typemux.ReplayConfig{})
+	if n != 1 || err != nil {
+		t.Fatalf("expected the replay to succeed, got %d, %v", n, err)
+	}
+	if len(handled) != 1 || handled[0] != "o1" {
+		t.Errorf("expected o1 handled, got %v", handled)
+	}
+	if letters, _ = sink.Letters(context.Background()); len(letters) != 0 {
+		t.Errorf("expected the letter deleted, got %v", letters)
+	}
+}
+
+// deleteFailingSink is a MemoryDeadLetterSink whose Delete always fails.
+type deleteFailingSink struct {
+	*typemux.MemoryDeadLetterSink[string, []byte]
+}
+
+func (s deleteFailingSink) Delete(ctx context.Context, id string) error {
+	return errCorrupt
+}
+
+func TestDeadLetter_ReplayFailures(t *testing.T) {
+	t.Run("dead_letter_middleware", func(t *testing.T) {
+		fail := true
+		var handled []string
+		reg := newFailingOrders(&fail, &handled)
+		sink := typemux.NewMemoryDeadLetterSink[string, []byte]()
+		dlq := typemux.DeadLetterDispatch[string, []byte](reg, sink, typemux.DeadLetterConfig{})
+
+		_ = typemux.Dispatch(reg, context.Background(), OrderPlaced{OrderID: "o1"}, dlq)
+		n, err := typemux.Replay(context.Background(), reg, sink, typemux.ReplayConfig{
+			Middleware: []typemux.DispatchMiddleware{dlq},
+		})
+		if n != 0 || !errors.Is(err, errDeadlock) {
+			t.Fatalf("expected the replay to fail, got %d, %v", n, err)
+		}
+		letters, _ := sink.Letters(context.Background())
+		if len(letters) != 1 || letters[0].Attempts != 2 {
+			t.Errorf("expected the one letter updated, got %+v", letters)
+		}
+	})
+
+	t.Run("delete_failure", func(t *testing.T) {
+		fail := false
+		var handled []string
+		reg := newFailingOrders(&fail, &handled)
+		sink := deleteFailingSink{typemux.NewMemoryDeadLetterSink[string, []byte]()}
+		_ = sink.Put(context.Background(), typemux.DeadLetter[string, []byte]{
+			ID: "l1", Key: "order_placed", Data: []byte(`{"order_id":"o1"}`), Attempts: 1,
+		})
+
+		n, err := typemux.Replay(context.Background(), reg, sink, typemux.ReplayConfig{})
+		if n != 0 || !errors.Is(err, errCorrupt) {
+			t.Errorf("expected a failed delete not to count, got %d, %v", n, err)
+		}
+	})
+
+	t.Run("decode_failure", func(t *testing.T) {
+		fail := false
+		var handled []string
+		reg := newFailingOrders(&fail, &handled)
+		sink := typemux.NewMemoryDeadLetterSink[string, []byte]()
+		_ = sink.Put(context.Background(), typemux.DeadLetter[string, []byte]{
+			ID: "l1", Key: "order_placed", Data: []byte(`{`), Attempts: 1,
+		})
+
+		n, err := typemux.Replay(context.Background(), reg, sink, typemux.ReplayConfig{})
+		if n != 0 || err == nil {
+			t.Fatalf("expected the replay to fail, got %d, %v", n, err)
+		}
+		letters, _ := sink.Letters(context.Background())
+		if len(letters) != 1 || letters[0].Attempts != 1 || letters[0].Error == "" {
+			t.Errorf("expected the error recorded without an attempt, got %+v", letters)
+		}
+		if len(handled) != 0 {
+			t.Errorf("expected nothing handled, got %v", handled)
+		}
+	})
+}
+
+func TestDeadLetter_SkipAndSerializeFailure(t *testing.T) {
+	fail := true
+	var handled []string
+	reg := newFailingOrders(&fail, &handled)
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error { return errDeadlock })
+	sink := typemux.NewMemoryDeadLetterSink[string, []byte]()
+
+	skip := typemux.DeadLetterDispatch[string, []byte](reg, sink, typemux.DeadLetterConfig{
+		Skip: func(err error) bool { return errors.Is(err, errDeadlock) },
+	})
+	if err := typemux.Dispatch(reg, context.Background(), OrderPlaced{}, skip); !errors.Is(err, errDeadlock) {
+		t.Fatalf("expected the dispatch error, got %v", err)
+	}
+
+	dlq := typemux.DeadLetterDispatch[string, []byte](reg, sink, typemux.DeadLetterConfig{})
+	err := typemux.Dispatch(reg, context.Background(), testEvent{}, dlq)
+	if !errors.Is(err, errDeadlock) || !errors.Is(err, typemux.ErrSerializerNotFound) {
+		t.Errorf("expected the dispatch error joined with the serialize error, got %v", err)
+	}
+
+	if letters, _ := sink.Letters(context.Background()); len(letters) != 0 {
+		t.Errorf("expected no letters, got %v", letters)
+	}
+}
+
+func TestFileDeadLetterSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dlq.jsonl")
+	ctx := context.Background()
+
+	sink, err := typemux.NewFileDeadLetterSink[string, []byte](path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	failed := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, id := range []string{"a", "b", "c"} {
+		letter := typemux.DeadLetter[string, []byte]{ID: id, Key: "k", Data: []byte(id), Attempts: 1, FirstFailed: failed, LastFailed: failed}
+		if err := sink.Put(ctx, letter); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := sink.Delete(ctx, "b"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := sink.Put(ctx, typemux.DeadLetter[string, []byte]{ID: "a", Key: "k", Data: []byte("a"), Attempts: 2}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sink, err = typemux.NewFileDeadLetterSink[string, []byte](path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer sink.Close()
+
+	letters, _ := sink.Letters(ctx)
+	if len(letters) != 2 || letters[0].ID != "a" || letters[1].ID != "c" {
+		t.Fatalf("expected letters a and c, got %+v", letters)
+	}
+	if letters[0].Attempts != 2 || string(letters[1].Data) != "c" || !letters[1].FirstFailed.Equal(failed) {
+		t.Errorf("expected the latest contents to survive reopening, got %+v", letters)
+	}
+}
@@ -0,0 +1,270 @@
+package typemux
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrNoMessageID is returned by dedup middleware for a value whose type has
+// neither a registered extractor nor a MessageID method.
+var ErrNoMessageID = errors.New("no message ID")
+
+// MessageIdentifier is implemented by values that carry their own message ID
+// for deduplication.
+type MessageIdentifier interface {
+	MessageID() string
+}
+
+// DedupStore records the IDs of processed messages.
+type DedupStore interface {
+	// Seen reports whether id has been recorded.
+	Seen(ctx context.Context, id string) (bool, error)
+	// Record marks id as processed.
+	Record(ctx context.Context, id string) error
+}
+
+// Deduplicator skips values whose message ID was already processed
+// successfully. Use NewDeduplicator to create one, RegisterMessageID for types
+// without a MessageID method, then attach Middleware or Dedup.
+//
+// IDs are stored prefixed with the value's type, qualified by its package
+// path, so different types may reuse the same ID; a pointer shares the prefix
+// of the type it points to. Concurrent deliveries of one message may both
+// run; the store only sees an ID once a handler has succeeded.
+type Deduplicator struct {
+	store DedupStore
+
+	mu         sync.RWMutex
+	extractors map[reflect.Type]func(any) string
+}
+
+// NewDeduplicator creates a Deduplicator recording IDs in store.
+func NewDeduplicator(store DedupStore) *Deduplicator {
+	return &Deduplicator{store: store, extractors: make(map[reflect.Type]func(any) string)}
+}
+
+// RegisterMessageID sets how the message ID of values of type T is derived.
+// It takes precedence over a MessageID method; pointers to T fall back to it,
+// as in Dispatch.
+func RegisterMessageID[T any](d *Deduplicator, id func(T) string) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.extractors[typ] = func(v any) string { return id(v.(T)) }
+}
+
+// Middleware returns a DispatchMiddleware that skips values already
+// processed, returning nil without calling the handler, and records each
+// value's ID once the dispatch succeeds.
+func (d *Deduplicator) Middleware() DispatchMiddleware {
+	return func(ctx context.Context, event any, next func(context.Context) error) error {
+		return d.do(ctx, event, next)
+	}
+}
+
+// Dedup returns a typed Middleware deduplicating values of type T through d,
+// with the same behavior as Deduplicator.Middleware.
+func Dedup[T any](d *Deduplicator) Middleware[T] {
+	return func(next HandlerFunc[T]) HandlerFunc[T] {
+		return func(ctx context.Context, val T) error {
+			return d.do(ctx, val, func(ctx context.Context) error {
+				return next(ctx, val)
+			})
+		}
+	}
+}
+
+func (d *Deduplicator) do(ctx context.Context, v any, call func(context.Context) error) error {
+	id, err := d.messageID(v)
+	if err != nil {
+		return err
+	}
+
+	seen, err := d.store.Seen(ctx, id)
+	if err != nil {
+		return fmt.Errorf("typemux: check message %q: %w", id, err)
+	}
+	if seen {
+		return nil
+	}
+
+	if err := call(ctx); err != nil {
+		return err
+	}
+
+	if err := d.store.Record(ctx, id); err != nil {
+		return fmt.Errorf("typemux: record message %q: %w", id, err)
+	}
+	return nil
+}
+
+func (d *Deduplicator) messageID(v any) (string, error) {
+	typ := reflect.TypeOf(v)
+
+	d.mu.RLock()
+	extract, ok := d.extractors[typ]
+	arg := v
+	if !ok && typ != nil && typ.Kind() == reflect.Ptr && !reflect.ValueOf(v).IsNil() {
+		if extract, ok = d.extractors[typ.Elem()]; ok {
+			arg = reflect.ValueOf(v).Elem().Interface()
+		}
+	}
+	d.mu.RUnlock()
+
+	if ok {
+		return messageNamespace(typ) + "/" + extract(arg), nil
+	}
+	if m, ok := v.(MessageIdentifier); ok {
+		return messageNamespace(typ) + "/" + m.MessageID(), nil
+	}
+	return "", fmt.Errorf("typemux: %w for type %v", ErrNoMessageID, typ)
+}
+
+// messageNamespace returns the ID prefix of values of typ: the qualified name
+// of the type, or of the type it points to.
+func messageNamespace(typ reflect.Type) string {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return qualifiedTypeName(typ)
+}
+
+// qualifiedTypeName returns typ's name qualified by its full package path,
+// unique across packages sharing a name; unnamed types use typ.String().
+func qualifiedTypeName(typ reflect.Type) string {
+	if typ.Name() == "" || typ.PkgPath() == "" {
+		return typ.String()
+	}
+	return typ.PkgPath() + "." + typ.Name()
+}
+
+// MemoryDedupStore is an in-memory DedupStore whose IDs expire after a TTL.
+type MemoryDedupStore struct {
+	ttl   time.Duration
+	clock Clock
+
+	mu      sync.Mutex
+	expires map[string]time.Time
+	swept   int
+}
+
+// NewMemoryDedupStore creates a MemoryDedupStore keeping IDs for ttl, or
+// forever if ttl is zero. A nil clock means the system clock.
+func NewMemoryDedupStore(ttl time.Duration, clock Clock) *MemoryDedupStore {
+	return &MemoryDedupStore{ttl: ttl, clock: clockOrSystem(clock), expires: make(map[string]time.Time)}
+}
+
+// Seen implements DedupStore.
+func (s *MemoryDedupStore) Seen(ctx context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expires, ok := s.expires[id]
+	if ok && s.ttl > 0 && !s.clock.Now().Before(expires) {
+		delete(s.expires, id)
+		return false, nil
+	}
+	return ok, nil
+}
+
+// Record implements DedupStore.
+func (s *MemoryDedupStore) Record(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	s.expires[id] = now.Add(s.ttl)
+
+	// Sweep expired IDs whenever the store has doubled since the last sweep,
+	// keeping the amortized cost per Record constant.
+	if s.ttl > 0 && len(s.expires) >= 2*max(s.swept, 64) {
+		for k, expires := range s.expires {
+			if !now.Before(expires) {
+				delete(s.expires, k)
+			}
+		}
+		s.swept = len(s.expires)
+	}
+	return nil
+}
+
+// FileDedupStore is a DedupStore persisting IDs to a file, one per line, so
+// they survive restarts. IDs never expire. Use NewFileDedupStore to open one
+// and Close to release the file.
+type FileDedupStore struct {
+	mu   sync.Mutex
+	f    *os.File
+	seen map[string]struct{}
+}
+
+// NewFileDedupStore opens the store at path, creating the file if needed and
+// loading the IDs it already holds.
+func NewFileDedupStore(path string) (*FileDedupStore, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("typemux: open dedup store: %w", err)
+	}
+
+	seen := make(map[string]struct{})
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		id, err := strconv.Unquote(scanner.Text())
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("typemux: read dedup store %s: %w", path, err)
+		}
+		seen[id] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("typemux: read dedup store %s: %w", path, err)
+	}
+
+	return &FileDedupStore{f: f, seen: seen}, nil
+}
+
+// Seen implements DedupStore.
+func (s *FileDedupStore) Seen(ctx context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.seen[id]
+	return ok, nil
+}
+
+// Record implements DedupStore. The ID is appended to the file and synced
+// before Record returns.
+func (s *FileDedupStore) Record(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.seen[id]; ok {
+		return nil
+	}
+	// Quoting keeps IDs containing newlines on one line.
+	if _, err := s.f.WriteString(strconv.Quote(id) + "\n"); err != nil {
+		return err
+	}
+	if err := s.f.Sync(); err != nil {
+		return err
+	}
+	s.seen[id] = struct{}{}
+	return nil
+}
+
+// Close closes the underlying file.
+func (s *FileDedupStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.f.Close()
+}
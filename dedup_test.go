@@ -0,0 +1,175 @@
+package typemux_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/struct0x/typemux"
+)
+
+type paymentReceived struct {
+	DeliveryID string
+	Amount     int
+}
+
+func (p paymentReceived) MessageID() string { return p.DeliveryID }
+
+func TestDeduplicator_SkipsProcessed(t *testing.T) {
+	dedup := typemux.NewDeduplicator(typemux.NewMemoryDedupStore(0, nil))
+	typemux.RegisterMessageID(dedup, func(o OrderPlaced) string { return o.OrderID })
+
+	var payments, orders int
+	reg := typemux.NewRegistry()
+	typemux.RegisterDispatch(reg, func(ctx context.Context, p paymentReceived) error {
+		payments++
+		return nil
+	})
+	typemux.RegisterDispatch(reg, func(ctx context.Context, o OrderPlaced) error {
+		orders++
+		return nil
+	}, typemux.Dedup[OrderPlaced](dedup))
+
+	mw := dedup.Middleware()
+	for range 3 {
+		if err := typemux.Dispatch(reg, context.Background(), paymentReceived{DeliveryID: "d1"}, mw); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := typemux.Dispatch(reg, context.Background(), OrderPlaced{OrderID: "d1"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// The same ID under another type is a different message.
+	if payments != 1 || orders != 1 {
+		t.Errorf("expected each message handled once, got %d payments and %d orders", payments, orders)
+	}
+}
+
+func TestDeduplicator_Pointers(t *testing.T) {
+	store := typemux.NewMemoryDedupStore(0, nil)
+	dedup := typemux.NewDeduplicator(store)
+	typemux.RegisterMessageID(dedup, func(o OrderPlaced) string { return o.OrderID })
+
+	var calls int
+	next := func(context.Context) error { calls++; return nil }
+
+	mw := dedup.Middleware()
+	for _, v := range []any{&OrderPlaced{OrderID: "o1"}, OrderPlaced{OrderID: "o1"}, &paymentReceived{DeliveryID: "d1"}, paymentReceived{DeliveryID: "d1"}} {
+		if err := mw(context.Background(), v, next); err != nil {
+			t.Fatalf("%T: unexpected error: %v", v, err)
+		}
+	}
+
+	// A pointer is the same message as the value it points to.
+	if calls != 2 {
+		t.Errorf("expected each message handled once, got %d calls", calls)
+	}
+	for _, id := range []string{"github.com/struct0x/typemux_test.OrderPlaced/o1", "github.com/struct0x/typemux_test.paymentReceived/d1"} {
+		if seen, _ := store.Seen(context.Background(), id); !seen {
+			t.Errorf("expected %q recorded", id)
+		}
+	}
+}
+
+func TestDeduplicator_RecordsOnlySuccess(t *testing.T) {
+	dedup := typemux.NewDeduplicator(typemux.NewMemoryDedupStore(time.Hour, nil))
+
+	var calls int
+	handler := typemux.Dedup[paymentReceived](dedup)(func(ctx context.Context, p paymentReceived) error {
+		calls++
+		if calls == 1 {
+			return errDeadlock
+		}
+		return nil
+	})
+
+	msg := paymentReceived{DeliveryID: "d1"}
+	if err := handler(context.Background(), msg); !errors.Is(err, errDeadlock) {
+		t.Fatalf("expected handler error, got %v", err)
+	}
+	for range 2 {
+		if err := handler(context.Background(), msg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("expected a retry after the failure and no call after the success, got %d calls", calls)
+	}
+}
+
+func TestDeduplicator_NoMessageID(t *testing.T) {
+	dedup := typemux.NewDeduplicator(typemux.NewMemoryDedupStore(0, nil))
+
+	err := dedup.Middleware()(context.Background(), testEvent{}, func(context.Context) error { return nil })
+	if !errors.Is(err, typemux.ErrNoMessageID) {
+		t.Fatalf("expected ErrNoMessageID, got %v", err)
+	}
+	const want = "typemux: no message ID for type typemux_test.testEvent"
+	if err.Error() != want {
+		t.Errorf("expected %q, got %q", want, err.Error())
+	}
+}
+
+func TestMemoryDedupStore_TTL(t *testing.T) {
+	clock := newFakeClock()
+	store := typemux.NewMemoryDedupStore(time.Minute, clock)
+	ctx := context.Background()
+
+	if err := store.Record(ctx, "m1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if seen, _ := store.Seen(ctx, "m1"); !seen {
+		t.Error("expected m1 seen")
+	}
+
+	clock.Advance(time.Minute)
+	if seen, _ := store.Seen(ctx, "m1"); seen {
+		t.Error("expected m1 expired")
+	}
+}
+
+func TestFileDedupStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dedup.log")
+	ctx := context.Background()
+
+	store, err := typemux.NewFileDedupStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, id := range []string{"m1", "multi\nline", "m1"} {
+		if err := store.Record(ctx, id); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "\"m1\"\n\"multi\\nline\"\n"; string(data) != want {
+		t.Errorf("expected file %q, got %q", want, data)
+	}
+
+	// IDs survive reopening.
+	store, err = typemux.NewFileDedupStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer store.Close()
+
+	for _, id := range []string{"m1", "multi\nline"} {
+		if seen, _ := store.Seen(ctx, id); !seen {
+			t.Errorf("expected %q seen after reopening", id)
+		}
+	}
+	if seen, _ := store.Seen(ctx, "m2"); seen {
+		t.Error("expected m2 unseen")
+	}
+}
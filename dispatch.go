@@ -2,6 +2,7 @@ package typemux
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 )
@@ -15,7 +16,20 @@ type HandlerFunc[T any] func(ctx context.Context, val T) error
 type Middleware[T any] func(next HandlerFunc[T]) HandlerFunc[T]
 
 type dispatchRegistry interface {
-	registerDispatch(reflect.Type, handlerFuncAny)
+	registerDispatch(reflect.Type, handlerBuilder) (unregister func() bool)
+}
+
+type typedMiddlewareRegistry interface {
+	attachMiddleware(typ reflect.Type, middleware []any)
+}
+
+// handlerBuilder builds a registered handler wrapped in the middleware
+// attached to its type through UseFor; attached holds Middleware[T] values,
+// outermost first.
+type handlerBuilder func(attached []any) handlerFuncAny
+
+type fallbackRegistry interface {
+	registerFallback(handlerFuncAny)
 }
 
 type dispatcher interface {
@@ -31,7 +45,12 @@ type DispatchMiddleware func(ctx context.Context, event any, next func(context.C
 
 // Dispatch dispatches the given value to a registered handler based on its concrete type.
 // Optional generic middleware is applied outermost-first, wrapping the typed middleware chain.
-// It returns ErrHandlerNotFound if no handler is registered for the value's type.
+// It returns ErrHandlerNotFound if no handler is registered for the value's type, and
+// ErrAmbiguousHandler if only interface handlers match and more than one does.
+//
+// Every error is a *DispatchError telling the value's type and the phase that
+// failed; the generic middleware sees the handler's *DispatchError from next.
+// Middleware wrapping that error, such as RetryDispatch, keeps its phase.
 func Dispatch(disp dispatcher, ctx context.Context, v any, middleware ...DispatchMiddleware) error {
 	typ := reflect.TypeOf(v)
 
@@ -39,10 +58,27 @@ func Dispatch(disp dispatcher, ctx context.Context, v any, middleware ...Dispatc
 		return disp.call(typ, ctx, v)
 	}
 
-	call := func(ctx context.Context) error {
-		return disp.call(typ, ctx, v)
+	// last is the latest error of the call itself; middleware passing it
+	// through unchanged doesn't make it a middleware failure.
+	var last error
+	err := chain(ctx, v, middleware, func(ctx context.Context) error {
+		last = disp.call(typ, ctx, v)
+		return last
+	})
+	if err == nil || err == last {
+		return err
+	}
+
+	phase := PhaseMiddleware
+	var inner *DispatchError
+	if last != nil && errors.Is(err, last) && errors.As(last, &inner) {
+		phase = inner.Phase
 	}
+	return &DispatchError{Type: typ, Phase: phase, Err: err}
+}
 
+// chain runs call wrapped in the generic middleware, outermost-first.
+func chain(ctx context.Context, v any, middleware []DispatchMiddleware, call func(context.Context) error) error {
 	for i := len(middleware) - 1; i >= 0; i-- {
 		mw := middleware[i]
 		next := call
@@ -59,12 +95,54 @@ func Dispatch(disp dispatcher, ctx context.Context, v any, middleware ...Dispatc
 // If a handler for the same type T has already been registered, it will be
 // replaced by the new handler and middleware chain.
 //
+// T may be an interface type, in which case the handler receives any value
+// whose concrete type implements T and has no handler of its own. Exact
+// matches (including the pointer fallback) always win over interface matches;
+// a value matching several registered interfaces fails with ErrAmbiguousHandler.
+//
 // Middleware is applied outermost first (i.e., the last middleware wraps the others).
-func RegisterDispatch[T any](reg dispatchRegistry, handler HandlerFunc[T], middleware ...Middleware[T]) {
+//
+// The returned Registration removes this handler again; it may be ignored.
+func RegisterDispatch[T any](reg dispatchRegistry, handler HandlerFunc[T], middleware ...Middleware[T]) Registration {
 	typ := reflect.TypeOf((*T)(nil)).Elem()
-	finalTyped := applyMiddleware(handler, middleware...)
+	finalTyped := applyMiddleware(emitting(handler), middleware...)
 
-	reg.registerDispatch(typ, wrapTypedHandler(finalTyped))
+	return Registration{unregister: reg.registerDispatch(typ, func(attached []any) handlerFuncAny {
+		final := finalTyped
+		for i := len(attached) - 1; i >= 0; i-- {
+			final = attached[i].(Middleware[T])(final)
+		}
+		return wrapTypedHandler(final)
+	})}
+}
+
+// UseFor attaches typed middleware to the dispatch handler for type T. It
+// applies to the handler registered now and to any handler registered for T
+// later, wrapping the middleware given to RegisterDispatch.
+//
+// Repeated calls append: all middleware attached to T is applied outermost
+// first, in the order it was attached. Registries sealed earlier are
+// unaffected, and on a child registry handlers inherited from an ancestor
+// are too.
+func UseFor[T any](reg typedMiddlewareRegistry, middleware ...Middleware[T]) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+
+	attached := make([]any, len(middleware))
+	for i, mw := range middleware {
+		attached[i] = mw
+	}
+	reg.attachMiddleware(typ, attached)
+}
+
+// RegisterFallback sets a catch-all handler for values that have no exact,
+// pointer-fallback or interface handler. With a fallback in place, Dispatch
+// no longer returns ErrHandlerNotFound; ambiguous interface matches still
+// fail with ErrAmbiguousHandler.
+//
+// A later RegisterFallback replaces the earlier one. The fallback is carried
+// over by Seal. Middleware is applied outermost first, as with RegisterDispatch.
+func RegisterFallback(reg fallbackRegistry, handler HandlerFunc[any], middleware ...Middleware[any]) {
+	reg.registerFallback(handlerFuncAny(applyMiddleware(emitting(handler), middleware...)))
 }
 
 func applyMiddleware[T any](base HandlerFunc[T], middleware ...Middleware[T]) HandlerFunc[T] {
@@ -76,11 +154,11 @@ func applyMiddleware[T any](base HandlerFunc[T], middleware ...Middleware[T]) Ha
 }
 
 func wrapTypedHandler[T any](h HandlerFunc[T]) handlerFuncAny {
-	var zero T
+	typ := reflect.TypeOf((*T)(nil)).Elem()
 	return func(ctx context.Context, v any) error {
 		val, ok := v.(T)
 		if !ok {
-			return fmt.Errorf("typemux: expected %T, got %T", zero, v)
+			return fmt.Errorf("typemux: expected %v, got %T", typ, v)
 		}
 		return h(ctx, val)
 	}
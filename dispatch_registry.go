@@ -4,63 +4,466 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"maps"
 	"reflect"
+	"slices"
 	"sync"
 )
 
 // ErrHandlerNotFound is returned when no handler is found for the given value's type.
 var ErrHandlerNotFound = errors.New("handler not found")
 
+// ErrAmbiguousHandler is returned when a value has no exact handler and its
+// type implements more than one interface with a registered handler.
+var ErrAmbiguousHandler = errors.New("ambiguous handler")
+
 // DispatchRegistry holds registered type-safe handlers.
 // Use NewDispatchRegistry() to create one, then RegisterDispatch() handlers.
 type DispatchRegistry struct {
-	mu sync.RWMutex
-	h  map[reflect.Type]handlerFuncAny
+	opts   options
+	parent *DispatchRegistry
+
+	mu       sync.RWMutex
+	h        map[reflect.Type]handlerFuncAny
+	ifaces   []reflect.Type
+	fallback handlerFuncAny
+	subs     map[reflect.Type][]handlerFuncAny
+	queries  map[reflect.Type]queryEntry
+	batches  map[reflect.Type]batchFuncAny
+
+	// Registry-level middleware: mw wraps every resolved handler, attached
+	// holds UseFor middleware per type, and builders rebuild h entries when
+	// more middleware is attached.
+	mw       []Middleware[any]
+	attached map[reflect.Type][]any
+	builders map[reflect.Type]handlerBuilder
+
+	// ids tracks which registration currently owns each handler, so a stale
+	// Registration can't remove a replacement.
+	ids    map[reflect.Type]uint64
+	nextID uint64
 }
 
 // NewDispatchRegistry creates a new empty DispatchRegistry.
 //
 // DispatchRegistry holds registered type-safe handlers.
-func NewDispatchRegistry() *DispatchRegistry {
+func NewDispatchRegistry(opts ...Option) *DispatchRegistry {
 	return &DispatchRegistry{
-		h: make(map[reflect.Type]handlerFuncAny),
+		opts:     newOptions(opts),
+		h:        make(map[reflect.Type]handlerFuncAny),
+		subs:     make(map[reflect.Type][]handlerFuncAny),
+		queries:  make(map[reflect.Type]queryEntry),
+		batches:  make(map[reflect.Type]batchFuncAny),
+		attached: make(map[reflect.Type][]any),
+		builders: make(map[reflect.Type]handlerBuilder),
+		ids:      make(map[reflect.Type]uint64),
 	}
 }
 
+// Child creates an empty DispatchRegistry whose lookups fall back to r for
+// anything it doesn't register itself: handlers, interface handlers, the
+// fallback handler, subscribers, queries and batch handlers. The child
+// inherits r's options, and r's registry-level middleware wraps the child's
+// own.
+//
+// Typed middleware attached to the child with UseFor only wraps handlers
+// registered on the child; a handler inherited from r keeps the middleware
+// attached on r.
+//
+// Later registrations on r are visible through the child. Sealing the child
+// flattens the chain into one immutable registry, so lookup cost doesn't grow
+// with depth.
+func (r *DispatchRegistry) Child() *DispatchRegistry {
+	child := NewDispatchRegistry()
+	child.opts = r.opts
+	child.parent = r
+	return child
+}
+
 func (r *DispatchRegistry) call(typ reflect.Type, ctx context.Context, v any) error {
+	if r.parent != nil {
+		return r.callInherited(typ, ctx, v)
+	}
+
 	r.mu.RLock()
-	defer r.mu.RUnlock()
+	handler, arg, ok := lookup(typ, v, r.h, r.opts.pointers)
+	var err error
+	if !ok {
+		handler, err = resolveIndirect(typ, mapGetter(r.h), r.ifaces, r.fallback)
+		arg = v
+	}
+	mw := r.mw
+	r.mu.RUnlock()
+
+	if err != nil {
+		return err
+	}
+	if len(mw) > 0 {
+		// Unsealed registries compose per call; Seal precomposes.
+		handler = withMiddleware(handler, mw)
+	}
+	return handlerError(typ, handler(ctx, arg))
+}
+
+// callInherited is call for a child registry: each lookup step takes the
+// nearest registry in the chain that has an entry.
+func (r *DispatchRegistry) callInherited(typ reflect.Type, ctx context.Context, v any) error {
+	handler, arg, ok := lookupPointer(typ, v, r.opts.pointers, r.inheritedHandler)
+	if !ok {
+		ifaces, fallback := r.inheritedIndirect()
+		var err error
+		if handler, err = resolveIndirect(typ, r.inheritedHandler, ifaces, fallback); err != nil {
+			return err
+		}
+		arg = v
+	}
+
+	if mw := r.inheritedMiddleware(); len(mw) > 0 {
+		handler = withMiddleware(handler, mw)
+	}
+	return handlerError(typ, handler(ctx, arg))
+}
+
+// inherit walks r and its ancestors, nearest first, and returns the first
+// entry get finds. Each registry is read under its own lock.
+func inherit[X any](r *DispatchRegistry, get func(*DispatchRegistry) (X, bool)) (X, bool) {
+	for reg := r; reg != nil; reg = reg.parent {
+		reg.mu.RLock()
+		x, ok := get(reg)
+		reg.mu.RUnlock()
+		if ok {
+			return x, true
+		}
+	}
+
+	var zero X
+	return zero, false
+}
+
+func (r *DispatchRegistry) inheritedHandler(typ reflect.Type) (handlerFuncAny, bool) {
+	return inherit(r, func(reg *DispatchRegistry) (handlerFuncAny, bool) {
+		h, ok := reg.h[typ]
+		return h, ok
+	})
+}
+
+// inheritedIndirect collects the interface types and fallback handler of the
+// whole chain, ancestors first.
+func (r *DispatchRegistry) inheritedIndirect() (ifaces []reflect.Type, fallback handlerFuncAny) {
+	for _, reg := range r.lineage() {
+		reg.mu.RLock()
+		for _, iface := range reg.ifaces {
+			if !slices.Contains(ifaces, iface) {
+				ifaces = append(ifaces, iface)
+			}
+		}
+		if reg.fallback != nil {
+			fallback = reg.fallback
+		}
+		reg.mu.RUnlock()
+	}
+	return ifaces, fallback
+}
+
+// inheritedMiddleware collects the registry-level middleware of the whole
+// chain, ancestors first. It only allocates when more than one registry in
+// the chain has middleware.
+func (r *DispatchRegistry) inheritedMiddleware() []Middleware[any] {
+	var mw []Middleware[any]
+	for reg := r; reg != nil; reg = reg.parent {
+		reg.mu.RLock()
+		if len(mw) == 0 {
+			mw = reg.mw
+		} else if len(reg.mw) > 0 {
+			mw = append(slices.Clip(reg.mw), mw...)
+		}
+		reg.mu.RUnlock()
+	}
+	return mw
+}
+
+// lineage returns the chain of registries from the root down to r.
+func (r *DispatchRegistry) lineage() []*DispatchRegistry {
+	var chain []*DispatchRegistry
+	for reg := r; reg != nil; reg = reg.parent {
+		chain = append(chain, reg)
+	}
+	slices.Reverse(chain)
+	return chain
+}
 
-	return call(typ, ctx, v, r.h)
+// Use appends registry-level middleware that wraps every handler Dispatch
+// resolves on this registry, including interface and fallback handlers.
+// Middleware is applied outermost first, outside any typed middleware.
+//
+// Seal composes the middleware into each handler once, so dispatch through a
+// sealed registry stays allocation-free; an unsealed registry composes it on
+// every call. Registries sealed earlier are unaffected.
+func (r *DispatchRegistry) Use(middleware ...Middleware[any]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.mw = append(slices.Clip(r.mw), middleware...)
+}
+
+func (r *DispatchRegistry) attachMiddleware(typ reflect.Type, middleware []any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.attached == nil {
+		r.attached = make(map[reflect.Type][]any)
+	}
+
+	r.attached[typ] = append(slices.Clip(r.attached[typ]), middleware...)
+	if build, ok := r.builders[typ]; ok {
+		r.h[typ] = r.build(typ, build)
+	}
 }
 
-func (r *DispatchRegistry) registerDispatch(typ reflect.Type, funcAny handlerFuncAny) {
+func (r *DispatchRegistry) registerDispatch(typ reflect.Type, build handlerBuilder) (unregister func() bool) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	if r.h == nil {
 		r.h = make(map[reflect.Type]handlerFuncAny)
 	}
+	if r.ids == nil {
+		r.ids = make(map[reflect.Type]uint64)
+	}
+	if r.builders == nil {
+		r.builders = make(map[reflect.Type]handlerBuilder)
+	}
+
+	_, replaced := r.h[typ]
+	if replaced && r.opts.strict {
+		panic(fmt.Errorf("typemux: %w: handler for type %v", ErrDuplicateRegistration, typ))
+	}
+	r.opts.logRegistration("handler", replaced, slog.String("type", typeName(typ)))
+
+	if typ.Kind() == reflect.Interface && !slices.Contains(r.ifaces, typ) {
+		r.ifaces = append(r.ifaces, typ)
+	}
+	r.h[typ] = r.build(typ, build)
+	r.builders[typ] = build
+
+	r.nextID++
+	id := r.nextID
+	r.ids[typ] = id
+
+	return func() bool {
+		return r.unregisterDispatch(typ, id)
+	}
+}
+
+// build builds the handler for typ with its attached middleware, the
+// limiter configured for it and panic recovery. r.mu must be held.
+func (r *DispatchRegistry) build(typ reflect.Type, build handlerBuilder) handlerFuncAny {
+	return r.opts.recovering(r.opts.limit(typ, build(r.attached[typ])))
+}
+
+func (r *DispatchRegistry) unregisterDispatch(typ reflect.Type, id uint64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.ids[typ] != id {
+		return false
+	}
+
+	delete(r.h, typ)
+	delete(r.builders, typ)
+	delete(r.ids, typ)
+	if typ.Kind() == reflect.Interface {
+		r.ifaces = slices.DeleteFunc(r.ifaces, func(t reflect.Type) bool { return t == typ })
+	}
+	return true
+}
+
+func (r *DispatchRegistry) registerFallback(funcAny handlerFuncAny) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.fallback != nil && r.opts.strict {
+		panic(fmt.Errorf("typemux: %w: fallback handler", ErrDuplicateRegistration))
+	}
+
+	r.fallback = r.opts.recovering(funcAny)
+}
+
+func (r *DispatchRegistry) registerSubscriber(typ reflect.Type, funcAny handlerFuncAny) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.subs == nil {
+		r.subs = make(map[reflect.Type][]handlerFuncAny)
+	}
+
+	// Clip forces append to copy, so slices handed out by subscribers stay
+	// untouched by later registrations.
+	r.subs[typ] = append(slices.Clip(r.subs[typ]), r.opts.recovering(funcAny))
+}
+
+func (r *DispatchRegistry) pointerPolicy() PointerPolicy {
+	return r.opts.pointers
+}
+
+func (r *DispatchRegistry) subscribers(typ reflect.Type) []handlerFuncAny {
+	subs, _ := inherit(r, func(reg *DispatchRegistry) ([]handlerFuncAny, bool) {
+		subs := reg.subs[typ]
+		return subs, len(subs) > 0
+	})
+	return subs
+}
+
+func (r *DispatchRegistry) registerQuery(reqType reflect.Type, entry queryEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.queries == nil {
+		r.queries = make(map[reflect.Type]queryEntry)
+	}
+
+	if _, ok := r.queries[reqType]; ok && r.opts.strict {
+		panic(fmt.Errorf("typemux: %w: query handler for type %v", ErrDuplicateRegistration, reqType))
+	}
+
+	r.queries[reqType] = r.opts.recoveringQuery(entry)
+}
+
+func (r *DispatchRegistry) query(reqType reflect.Type) (queryEntry, bool) {
+	return inherit(r, func(reg *DispatchRegistry) (queryEntry, bool) {
+		e, ok := reg.queries[reqType]
+		return e, ok
+	})
+}
+
+func (r *DispatchRegistry) registerBatch(typ reflect.Type, funcAny batchFuncAny) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.batches == nil {
+		r.batches = make(map[reflect.Type]batchFuncAny)
+	}
+
+	if _, ok := r.batches[typ]; ok && r.opts.strict {
+		panic(fmt.Errorf("typemux: %w: batch handler for type %v", ErrDuplicateRegistration, typ))
+	}
+
+	r.batches[typ] = r.opts.recoveringBatch(typ, funcAny)
+}
 
-	r.h[typ] = funcAny
+func (r *DispatchRegistry) batchHandler(typ reflect.Type) (batchFuncAny, bool) {
+	return inherit(r, func(reg *DispatchRegistry) (batchFuncAny, bool) {
+		b, ok := reg.batches[typ]
+		return b, ok
+	})
 }
 
 // Seal finalizes the DispatchRegistry and returns a SealedDispatchRegistry.
+//
+// For a child registry, the entries of all its ancestors are flattened into
+// the result, the nearest registry winning for each entry.
 func (r *DispatchRegistry) Seal() *SealedDispatchRegistry {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	s := &SealedDispatchRegistry{
+		pointers: r.opts.pointers,
+		h:        make(map[reflect.Type]handlerFuncAny),
+		subs:     make(map[reflect.Type][]handlerFuncAny),
+		queries:  make(map[reflect.Type]queryEntry),
+		batches:  make(map[reflect.Type]batchFuncAny),
+	}
+
+	var mw []Middleware[any]
+	for _, reg := range r.lineage() {
+		reg.mu.RLock()
+		maps.Copy(s.h, reg.h)
+		for _, iface := range reg.ifaces {
+			if !slices.Contains(s.ifaces, iface) {
+				s.ifaces = append(s.ifaces, iface)
+			}
+		}
+		if reg.fallback != nil {
+			s.fallback = reg.fallback
+		}
+		maps.Copy(s.subs, reg.subs)
+		maps.Copy(s.queries, reg.queries)
+		maps.Copy(s.batches, reg.batches)
+		mw = append(mw, reg.mw...)
+		reg.mu.RUnlock()
+	}
+
+	if len(mw) > 0 {
+		for typ, handler := range s.h {
+			s.h[typ] = withMiddleware(handler, mw)
+		}
+		if s.fallback != nil {
+			s.fallback = withMiddleware(s.fallback, mw)
+		}
+	}
 
-	return &SealedDispatchRegistry{h: maps.Clone(r.h)}
+	return s
 }
 
 // SealedDispatchRegistry is an immutable, thread-safe dispatcher.
+//
+// Interface matches are resolved once per concrete type and cached, so
+// steady-state dispatch stays allocation-free.
 type SealedDispatchRegistry struct {
-	h map[reflect.Type]handlerFuncAny
+	pointers PointerPolicy
+
+	h        map[reflect.Type]handlerFuncAny
+	ifaces   []reflect.Type
+	fallback handlerFuncAny
+	subs     map[reflect.Type][]handlerFuncAny
+	queries  map[reflect.Type]queryEntry
+	batches  map[reflect.Type]batchFuncAny
+
+	// matches caches interface and fallback resolution: reflect.Type -> *interfaceMatch.
+	matches sync.Map
+}
+
+type interfaceMatch struct {
+	handler handlerFuncAny
+	err     error
 }
 
 func (s *SealedDispatchRegistry) call(typ reflect.Type, ctx context.Context, v any) error {
-	return call(typ, ctx, v, s.h)
+	if handler, arg, ok := lookup(typ, v, s.h, s.pointers); ok {
+		return handlerError(typ, handler(ctx, arg))
+	}
+
+	var m *interfaceMatch
+	if cached, ok := s.matches.Load(typ); ok {
+		m = cached.(*interfaceMatch)
+	} else {
+		handler, err := resolveIndirect(typ, mapGetter(s.h), s.ifaces, s.fallback)
+		m = &interfaceMatch{handler: handler, err: err}
+		if typ != nil {
+			s.matches.Store(typ, m)
+		}
+	}
+
+	if m.err != nil {
+		return m.err
+	}
+	return handlerError(typ, m.handler(ctx, v))
+}
+
+func (s *SealedDispatchRegistry) pointerPolicy() PointerPolicy {
+	return s.pointers
+}
+
+func (s *SealedDispatchRegistry) subscribers(typ reflect.Type) []handlerFuncAny {
+	return s.subs[typ]
+}
+
+func (s *SealedDispatchRegistry) batchHandler(typ reflect.Type) (batchFuncAny, bool) {
+	b, ok := s.batches[typ]
+	return b, ok
+}
+
+func (s *SealedDispatchRegistry) query(reqType reflect.Type) (queryEntry, bool) {
+	e, ok := s.queries[reqType]
+	return e, ok
 }
 
 // Registry is a composite registry that combines dispatch handlers and codecs.
@@ -77,10 +480,21 @@ type Registry struct {
 }
 
 // NewRegistry creates a new composite Registry with handler + codec support.
-func NewRegistry() *Registry {
+// The options apply to both halves.
+func NewRegistry(opts ...Option) *Registry {
 	return &Registry{
-		DispatchRegistry: NewDispatchRegistry(),
-		CodecRegistry:    NewCodecRegistry(),
+		DispatchRegistry: NewDispatchRegistry(opts...),
+		CodecRegistry:    NewCodecRegistry(opts...),
+	}
+}
+
+// Child creates an empty Registry whose dispatch and codec lookups fall back
+// to r for anything it doesn't register itself. See DispatchRegistry.Child
+// and CodecRegistry.Child.
+func (r *Registry) Child() *Registry {
+	return &Registry{
+		DispatchRegistry: r.DispatchRegistry.Child(),
+		CodecRegistry:    r.CodecRegistry.Child(),
 	}
 }
 
@@ -95,23 +509,81 @@ func (r *Registry) Seal() *SealedRegistry {
 	}
 }
 
+func (r *Registry) pointerPolicy() PointerPolicy {
+	return r.DispatchRegistry.pointerPolicy()
+}
+
 // SealedRegistry is an immutable composite registry for runtime use.
 type SealedRegistry struct {
 	*SealedDispatchRegistry
 	*SealedCodecRegistry
 }
 
-func call(typ reflect.Type, ctx context.Context, v any, h map[reflect.Type]handlerFuncAny) error {
-	if handler, ok := h[typ]; ok {
-		return handler(ctx, v)
+func (s *SealedRegistry) pointerPolicy() PointerPolicy {
+	return s.SealedDispatchRegistry.pointerPolicy()
+}
+
+// withMiddleware wraps handler in registry-level middleware, outermost first.
+func withMiddleware(handler handlerFuncAny, middleware []Middleware[any]) handlerFuncAny {
+	return handlerFuncAny(applyMiddleware(HandlerFunc[any](handler), middleware...))
+}
+
+// mapGetter adapts a handler map to the lookup function of resolveIndirect.
+func mapGetter(h map[reflect.Type]handlerFuncAny) func(reflect.Type) (handlerFuncAny, bool) {
+	return func(t reflect.Type) (handlerFuncAny, bool) {
+		handler, ok := h[t]
+		return handler, ok
 	}
+}
+
+// lookup resolves the handler for typ by exact match or the pointer
+// fallbacks allowed by policy. It returns the argument to pass to the
+// handler, which is v converted to the matched type in the fallback cases.
+func lookup(typ reflect.Type, v any, h map[reflect.Type]handlerFuncAny, policy PointerPolicy) (handlerFuncAny, any, bool) {
+	return lookupPointer(typ, v, policy, func(t reflect.Type) (handlerFuncAny, bool) {
+		handler, ok := h[t]
+		return handler, ok
+	})
+}
+
+// resolveIndirect resolves the handler for a type without an exact match:
+// a single matching interface handler, or else the fallback handler if set.
+// Failures are reported as a *DispatchError in PhaseLookup.
+func resolveIndirect(typ reflect.Type, get func(reflect.Type) (handlerFuncAny, bool), ifaces []reflect.Type, fallback handlerFuncAny) (handlerFuncAny, error) {
+	handler, err := matchInterface(typ, get, ifaces)
+	if err == nil {
+		return handler, nil
+	}
+	if fallback != nil && errors.Is(err, ErrHandlerNotFound) {
+		return fallback, nil
+	}
+	return nil, &DispatchError{Type: typ, Phase: PhaseLookup, Err: err}
+}
 
-	// Fallback: if v is a pointer, try the element type
-	if typ.Kind() == reflect.Ptr {
-		if handler, ok := h[typ.Elem()]; ok {
-			return handler(ctx, reflect.ValueOf(v).Elem().Interface())
+// matchInterface resolves the handler registered for the single interface in
+// ifaces that typ implements.
+func matchInterface(typ reflect.Type, get func(reflect.Type) (handlerFuncAny, bool), ifaces []reflect.Type) (handlerFuncAny, error) {
+	if typ == nil {
+		return nil, fmt.Errorf("typemux: %w for type %v", ErrHandlerNotFound, typ)
+	}
+
+	var (
+		handler handlerFuncAny
+		matched []reflect.Type
+	)
+	for _, iface := range ifaces {
+		if typ.Implements(iface) {
+			handler, _ = get(iface)
+			matched = append(matched, iface)
 		}
 	}
 
-	return fmt.Errorf("typemux: %w for type %v", ErrHandlerNotFound, typ)
+	switch len(matched) {
+	case 0:
+		return nil, fmt.Errorf("typemux: %w for type %v", ErrHandlerNotFound, typ)
+	case 1:
+		return handler, nil
+	default:
+		return nil, fmt.Errorf("typemux: %w: type %v implements %v", ErrAmbiguousHandler, typ, matched)
+	}
 }
@@ -0,0 +1,230 @@
+package typemux_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/struct0x/typemux"
+)
+
+func TestChild_FallsBackToParent(t *testing.T) {
+	base := typemux.NewRegistry()
+
+	var out string
+	typemux.RegisterDispatch(base, func(ctx context.Context, e testEvent) error {
+		out = "base:" + e.Name
+		return nil
+	})
+	typemux.RegisterDispatch(base, func(ctx context.Context, o OrderPlaced) error {
+		out = "base:" + o.OrderID
+		return nil
+	})
+
+	tenant := base.Child()
+	typemux.RegisterDispatch(tenant, func(ctx context.Context, o OrderPlaced) error {
+		out = "tenant:" + o.OrderID
+		return nil
+	})
+
+	for name, disp := range map[string]func(v any) error{
+		"standard_registry": func(v any) error { return typemux.Dispatch(tenant, context.Background(), v) },
+		"sealed_registry":   func(v any) error { return typemux.Dispatch(tenant.Seal(), context.Background(), v) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := disp(&testEvent{Name: "e1"}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if out != "base:e1" {
+				t.Errorf("expected parent handler, got %s", out)
+			}
+
+			if err := disp(OrderPlaced{OrderID: "o1"}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if out != "tenant:o1" {
+				t.Errorf("expected child override, got %s", out)
+			}
+		})
+	}
+
+	// The parent is unaffected by the child's override.
+	if err := typemux.Dispatch(base, context.Background(), OrderPlaced{OrderID: "o2"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "base:o2" {
+		t.Errorf("expected parent handler, got %s", out)
+	}
+}
+
+func TestChild_SeesLaterParentRegistrations(t *testing.T) {
+	base := typemux.NewDispatchRegistry()
+	child := base.Child().Child()
+
+	if err := typemux.Dispatch(child, context.Background(), testEvent{}); !errors.Is(err, typemux.ErrHandlerNotFound) {
+		t.Fatalf("expected ErrHandlerNotFound, got %v", err)
+	}
+
+	typemux.RegisterDispatch(base, func(ctx context.Context, e testEvent) error { return nil })
+
+	if err := typemux.Dispatch(child, context.Background(), testEvent{}); err != nil {
+		t.Errorf("expected grandparent handler, got %v", err)
+	}
+}
+
+func TestChild_IndirectLookupsAndMiddleware(t *testing.T) {
+	base := typemux.NewRegistry()
+
+	var order []string
+	base.Use(func(next typemux.HandlerFunc[any]) typemux.HandlerFunc[any] {
+		return func(ctx context.Context, v any) error {
+			order = append(order, "base-mw")
+			return next(ctx, v)
+		}
+	})
+	typemux.RegisterDispatch(base, func(ctx context.Context, a auditable) error {
+		order = append(order, "base-interface")
+		return nil
+	})
+	typemux.RegisterFallback(base, func(ctx context.Context, v any) error {
+		order = append(order, "base-fallback")
+		return nil
+	})
+
+	child := base.Child()
+	child.Use(func(next typemux.HandlerFunc[any]) typemux.HandlerFunc[any] {
+		return func(ctx context.Context, v any) error {
+			order = append(order, "child-mw")
+			return next(ctx, v)
+		}
+	})
+	// An exact match in the parent still beats an interface in the child.
+	typemux.RegisterDispatch(base, func(ctx context.Context, e auditedEvent) error {
+		order = append(order, "base-exact")
+		return nil
+	})
+	typemux.RegisterDispatch(child, func(ctx context.Context, s tenantScoped) error {
+		order = append(order, "child-interface")
+		return nil
+	})
+
+	for name, disp := range map[string]func(v any) error{
+		"standard_registry": func(v any) error { return typemux.Dispatch(child, context.Background(), v) },
+		"sealed_registry":   func(v any) error { return typemux.Dispatch(child.Seal(), context.Background(), v) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			order = nil
+			for _, v := range []any{auditedEvent{}, unknownEvent{}} {
+				if err := disp(v); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if err := disp(scopedEvent{}); !errors.Is(err, typemux.ErrAmbiguousHandler) {
+				t.Errorf("expected interfaces of the whole chain to be ambiguous, got %v", err)
+			}
+
+			expected := []string{
+				"base-mw", "child-mw", "base-exact",
+				"base-mw", "child-mw", "base-fallback",
+			}
+			if !reflect.DeepEqual(order, expected) {
+				t.Errorf("expected %v, got %v", expected, order)
+			}
+		})
+	}
+}
+
+func TestChild_UseForSkipsInheritedHandlers(t *testing.T) {
+	base := typemux.NewRegistry()
+	typemux.RegisterDispatch(base, func(ctx context.Context, e testEvent) error { return nil })
+
+	var wrapped int
+	child := base.Child()
+	typemux.UseFor(child, func(next typemux.HandlerFunc[testEvent]) typemux.HandlerFunc[testEvent] {
+		return func(ctx context.Context, e testEvent) error {
+			wrapped++
+			return next(ctx, e)
+		}
+	})
+
+	for name, disp := range map[string]func() error{
+		"standard_registry": func() error { return typemux.Dispatch(child, context.Background(), testEvent{}) },
+		"sealed_registry":   func() error { return typemux.Dispatch(child.Seal(), context.Background(), testEvent{}) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			wrapped = 0
+			if err := disp(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if wrapped != 0 {
+				t.Errorf("expected the inherited handler unwrapped, got %d calls", wrapped)
+			}
+		})
+	}
+
+	// A handler registered on the child picks the middleware up.
+	typemux.RegisterDispatch(child, func(ctx context.Context, e testEvent) error { return nil })
+	wrapped = 0
+	if err := typemux.Dispatch(child, context.Background(), testEvent{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if wrapped != 1 {
+		t.Errorf("expected the child's handler wrapped once, got %d", wrapped)
+	}
+}
+
+func TestChild_Codecs(t *testing.T) {
+	base := typemux.NewRegistry()
+	typemux.RegisterCodec(base, "user_created", typemux.JSONCodec[UserCreated]())
+	typemux.RegisterCodec(base, "order_placed", typemux.JSONCodec[OrderPlaced]())
+
+	child := base.Child()
+	typemux.RegisterCodec(child, "order_placed", typemux.NewCodec(
+		func(o OrderPlaced) ([]byte, error) { return []byte(o.OrderID), nil },
+		func(b []byte) (OrderPlaced, error) { return OrderPlaced{OrderID: "child:" + string(b)}, nil },
+	))
+
+	sealed := child.Seal()
+
+	if _, err := typemux.CreateType(sealed, "user_created", []byte(`{"id":"u1"}`)); err != nil {
+		t.Errorf("expected parent factory, got %v", err)
+	}
+	if _, _, err := typemux.Serialize[string, []byte](child, UserCreated{}); err != nil {
+		t.Errorf("expected parent serializer, got %v", err)
+	}
+
+	got, err := typemux.CreateType(child, "order_placed", []byte("o1"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.(OrderPlaced).OrderID != "child:o1" {
+		t.Errorf("expected child factory, got %+v", got)
+	}
+	_, data, err := typemux.Serialize[string, []byte](sealed, OrderPlaced{OrderID: "o2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(data) != "o2" {
+		t.Errorf("expected child serializer, got %s", data)
+	}
+
+	if _, err := typemux.CreateType(child, "user_created", "not bytes"); !errors.Is(err, typemux.ErrDataTypeNotSupported) {
+		t.Errorf("expected ErrDataTypeNotSupported through the chain, got %v", err)
+	}
+}
+
+func TestChild_InheritsOptions(t *testing.T) {
+	base := typemux.NewRegistry(typemux.WithStrictDuplicates())
+	child := base.Child()
+
+	typemux.RegisterDispatch(base, func(ctx context.Context, e testEvent) error { return nil })
+
+	// Overriding the parent is not a duplicate...
+	typemux.RegisterDispatch(child, func(ctx context.Context, e testEvent) error { return nil })
+
+	// ...but registering twice on the child is.
+	expectDuplicatePanic(t, "testEvent", func() {
+		typemux.RegisterDispatch(child, func(ctx context.Context, e testEvent) error { return nil })
+	})
+}
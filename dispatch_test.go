@@ -2,6 +2,7 @@ package typemux_test
 
 import (
 	"context"
+	"errors"
 	"reflect"
 	"testing"
 
@@ -173,3 +174,370 @@ func TestDispatch_MultipleGenericMiddleware(t *testing.T) {
 		t.Errorf("expected %v, got %v", expected, order)
 	}
 }
+
+type auditable interface {
+	AuditID() string
+}
+
+type tenantScoped interface {
+	TenantID() string
+}
+
+type auditedEvent struct {
+	ID string
+}
+
+func (e auditedEvent) AuditID() string { return e.ID }
+
+type scopedEvent struct {
+	ID string
+}
+
+func (e scopedEvent) AuditID() string  { return e.ID }
+func (e scopedEvent) TenantID() string { return "tenant-" + e.ID }
+
+func TestDispatch_InterfaceHandler(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	var received string
+	typemux.RegisterDispatch(reg, func(ctx context.Context, a auditable) error {
+		received = a.AuditID()
+		return nil
+	})
+
+	t.Run("standard_registry", func(t *testing.T) {
+		if err := typemux.Dispatch(reg, context.Background(), auditedEvent{ID: "value"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if received != "value" {
+			t.Errorf("expected 'value', got: %s", received)
+		}
+	})
+
+	t.Run("sealed_registry", func(t *testing.T) {
+		sealed := reg.Seal()
+
+		// Twice, so the second call goes through the cached match.
+		for _, id := range []string{"first", "second"} {
+			if err := typemux.Dispatch(sealed, context.Background(), &auditedEvent{ID: id}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if received != id {
+				t.Errorf("expected %q, got: %s", id, received)
+			}
+		}
+	})
+}
+
+func TestDispatch_ExactMatchBeatsInterface(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	var out string
+	typemux.RegisterDispatch(reg, func(ctx context.Context, a auditable) error {
+		out = "interface"
+		return nil
+	})
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e auditedEvent) error {
+		out = "exact"
+		return nil
+	})
+
+	t.Run("standard_registry", func(t *testing.T) {
+		out = ""
+		if err := typemux.Dispatch(reg, context.Background(), &auditedEvent{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if out != "exact" {
+			t.Errorf("expected exact handler, got: %s", out)
+		}
+	})
+
+	t.Run("sealed_registry", func(t *testing.T) {
+		out = ""
+		if err := typemux.Dispatch(reg.Seal(), context.Background(), &auditedEvent{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if out != "exact" {
+			t.Errorf("expected exact handler, got: %s", out)
+		}
+	})
+}
+
+func TestDispatch_AmbiguousInterfaces(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	typemux.RegisterDispatch(reg, func(ctx context.Context, a auditable) error { return nil })
+	typemux.RegisterDispatch(reg, func(ctx context.Context, s tenantScoped) error { return nil })
+
+	err := typemux.Dispatch(reg, context.Background(), scopedEvent{ID: "1"})
+	if !errors.Is(err, typemux.ErrAmbiguousHandler) {
+		t.Errorf("expected ErrAmbiguousHandler, got %v", err)
+	}
+
+	sealed := reg.Seal()
+	for range 2 {
+		err = typemux.Dispatch(sealed, context.Background(), scopedEvent{ID: "1"})
+		if !errors.Is(err, typemux.ErrAmbiguousHandler) {
+			t.Errorf("expected ErrAmbiguousHandler from sealed registry, got %v", err)
+		}
+	}
+
+	// Only one interface matches auditedEvent.
+	if err := typemux.Dispatch(sealed, context.Background(), auditedEvent{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestDispatch_InterfaceHandler_NotFound(t *testing.T) {
+	reg := typemux.NewRegistry()
+	typemux.RegisterDispatch(reg, func(ctx context.Context, s tenantScoped) error { return nil })
+
+	err := typemux.Dispatch(reg.Seal(), context.Background(), auditedEvent{})
+	if !errors.Is(err, typemux.ErrHandlerNotFound) {
+		t.Errorf("expected ErrHandlerNotFound, got %v", err)
+	}
+}
+
+func TestDispatch_InterfaceHandler_SealedZeroAlloc(t *testing.T) {
+	reg := typemux.NewRegistry()
+	typemux.RegisterDispatch(reg, func(ctx context.Context, a auditable) error { return nil })
+	sealed := reg.Seal()
+
+	ctx := context.Background()
+	var ev auditable = &auditedEvent{ID: "1"}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = typemux.Dispatch(sealed, ctx, ev)
+	})
+	if allocs != 0 {
+		t.Errorf("expected zero allocations, got %v", allocs)
+	}
+}
+
+type unknownEvent struct {
+	Kind string
+}
+
+func TestRegisterFallback_DispatchRegistry(t *testing.T) {
+	reg := typemux.NewDispatchRegistry()
+
+	var forwarded []any
+	typemux.RegisterFallback(reg, func(ctx context.Context, v any) error {
+		forwarded = append(forwarded, v)
+		return nil
+	})
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error {
+		return nil
+	})
+
+	ctx := context.Background()
+	sealed := reg.Seal()
+
+	if err := typemux.Dispatch(reg, ctx, unknownEvent{Kind: "a"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := typemux.Dispatch(sealed, ctx, unknownEvent{Kind: "b"}); err != nil {
+		t.Fatalf("unexpected error from sealed registry: %v", err)
+	}
+	// Handled values never reach the fallback, pointers included.
+	if err := typemux.Dispatch(sealed, ctx, &testEvent{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []any{unknownEvent{Kind: "a"}, unknownEvent{Kind: "b"}}
+	if !reflect.DeepEqual(forwarded, expected) {
+		t.Errorf("expected %v, got %v", expected, forwarded)
+	}
+}
+
+func TestRegisterFallback_Registry(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	var out string
+	typemux.RegisterDispatch(reg, func(ctx context.Context, a auditable) error {
+		out = "interface"
+		return nil
+	})
+	typemux.RegisterFallback(reg, func(ctx context.Context, v any) error {
+		out = "fallback"
+		return nil
+	})
+
+	sealed := reg.Seal()
+	ctx := context.Background()
+
+	if err := typemux.Dispatch(sealed, ctx, auditedEvent{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "interface" {
+		t.Errorf("expected interface handler to win over fallback, got: %s", out)
+	}
+
+	if err := typemux.Dispatch(reg, ctx, unknownEvent{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "fallback" {
+		t.Errorf("expected fallback, got: %s", out)
+	}
+}
+
+func TestRegisterFallback_AmbiguityStillFails(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	typemux.RegisterDispatch(reg, func(ctx context.Context, a auditable) error { return nil })
+	typemux.RegisterDispatch(reg, func(ctx context.Context, s tenantScoped) error { return nil })
+	typemux.RegisterFallback(reg, func(ctx context.Context, v any) error { return nil })
+
+	err := typemux.Dispatch(reg.Seal(), context.Background(), scopedEvent{})
+	if !errors.Is(err, typemux.ErrAmbiguousHandler) {
+		t.Errorf("expected ErrAmbiguousHandler, got %v", err)
+	}
+}
+
+func TestRegistryUse_WrapsEveryHandler(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	var order []string
+	trace := func(next typemux.HandlerFunc[any]) typemux.HandlerFunc[any] {
+		return func(ctx context.Context, v any) error {
+			order = append(order, "use:"+reflect.TypeOf(v).Name())
+			return next(ctx, v)
+		}
+	}
+
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error {
+		order = append(order, "handler")
+		return nil
+	})
+	typemux.RegisterFallback(reg, func(ctx context.Context, v any) error {
+		order = append(order, "fallback")
+		return nil
+	})
+
+	// Use may come after registration.
+	reg.Use(trace)
+
+	for name, run := range map[string]func(v any) error{
+		"standard_registry": func(v any) error { return typemux.Dispatch(reg, context.Background(), v) },
+		"sealed_registry":   func(v any) error { return typemux.Dispatch(reg.Seal(), context.Background(), v) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			order = nil
+			if err := run(&testEvent{}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := run(unknownEvent{}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			expected := []string{"use:testEvent", "handler", "use:unknownEvent", "fallback"}
+			if !reflect.DeepEqual(order, expected) {
+				t.Errorf("expected %v, got %v", expected, order)
+			}
+		})
+	}
+}
+
+func TestUseFor_AttachesAfterRegistration(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	var order []string
+	named := func(name string) typemux.Middleware[testEvent] {
+		return func(next typemux.HandlerFunc[testEvent]) typemux.HandlerFunc[testEvent] {
+			return func(ctx context.Context, e testEvent) error {
+				order = append(order, name)
+				return next(ctx, e)
+			}
+		}
+	}
+
+	reg.Use(func(next typemux.HandlerFunc[any]) typemux.HandlerFunc[any] {
+		return func(ctx context.Context, v any) error {
+			order = append(order, "global")
+			return next(ctx, v)
+		}
+	})
+
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error {
+		order = append(order, "handler")
+		return nil
+	}, named("registration"))
+
+	sealedBefore := reg.Seal()
+
+	typemux.UseFor(reg, named("attached1"), named("attached2"))
+	typemux.UseFor(reg, named("attached3"))
+
+	if err := typemux.Dispatch(reg.Seal(), context.Background(), testEvent{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"global", "attached1", "attached2", "attached3", "registration", "handler"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("expected %v, got %v", expected, order)
+	}
+
+	order = nil
+	if err := typemux.Dispatch(sealedBefore, context.Background(), testEvent{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected = []string{"global", "registration", "handler"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("expected earlier seal to be unaffected: %v, got %v", expected, order)
+	}
+}
+
+func TestUseFor_AppliesToLaterRegistration(t *testing.T) {
+	reg := typemux.NewDispatchRegistry()
+
+	var order []string
+	typemux.UseFor(reg, typemux.MiddlewareFunc(func(ctx context.Context, e testEvent) (bool, error) {
+		order = append(order, "attached")
+		return true, nil
+	}))
+
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error {
+		order = append(order, "handler")
+		return nil
+	})
+
+	if err := typemux.Dispatch(reg, context.Background(), testEvent{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"attached", "handler"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("expected %v, got %v", expected, order)
+	}
+}
+
+func TestRegistryUse_SealedZeroAlloc(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	var calls int
+	reg.Use(func(next typemux.HandlerFunc[any]) typemux.HandlerFunc[any] {
+		return func(ctx context.Context, v any) error {
+			calls++
+			return next(ctx, v)
+		}
+	})
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e *testEvent) error { return nil })
+	typemux.UseFor(reg, func(next typemux.HandlerFunc[*testEvent]) typemux.HandlerFunc[*testEvent] {
+		return func(ctx context.Context, e *testEvent) error {
+			calls++
+			return next(ctx, e)
+		}
+	})
+
+	sealed := reg.Seal()
+	ctx := context.Background()
+	var ev any = &testEvent{}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = typemux.Dispatch(sealed, ctx, ev)
+	})
+	if allocs != 0 {
+		t.Errorf("expected zero allocations, got %v", allocs)
+	}
+	if calls == 0 {
+		t.Error("expected middleware to run")
+	}
+}
@@ -0,0 +1,111 @@
+package typemux
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// DispatchPhase tells which step of a dispatch failed.
+type DispatchPhase int
+
+const (
+	// PhaseLookup means no handler could be resolved for the value's type.
+	PhaseLookup DispatchPhase = iota
+	// PhaseHandler means the resolved handler failed, including the typed and
+	// registry-level middleware wrapping it.
+	PhaseHandler
+	// PhaseMiddleware means a DispatchMiddleware passed to Dispatch failed on
+	// its own, e.g. with ErrCircuitOpen, or replaced the call's error instead
+	// of wrapping it.
+	PhaseMiddleware
+)
+
+func (p DispatchPhase) String() string {
+	switch p {
+	case PhaseLookup:
+		return "lookup"
+	case PhaseHandler:
+		return "handler"
+	case PhaseMiddleware:
+		return "middleware"
+	default:
+		return fmt.Sprintf("DispatchPhase(%d)", int(p))
+	}
+}
+
+// DispatchError is returned by Dispatch when dispatching a value fails. Its
+// message is that of Err, and errors.Is and errors.As see through it.
+type DispatchError struct {
+	// Type is the concrete type of the dispatched value; nil for a nil value.
+	Type reflect.Type
+	// Phase is the step that failed.
+	Phase DispatchPhase
+	// Err is the underlying error, e.g. wrapping ErrHandlerNotFound or
+	// returned by the handler.
+	Err error
+}
+
+func (e *DispatchError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DispatchError) Unwrap() error {
+	return e.Err
+}
+
+// handlerError reports err, returned by the handler resolved for typ, as a
+// *DispatchError.
+func handlerError(typ reflect.Type, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &DispatchError{Type: typ, Phase: PhaseHandler, Err: err}
+}
+
+// CodecDirection tells which half of a codec an error comes from.
+type CodecDirection int
+
+const (
+	// CodecUnmarshal is the direction of CreateType.
+	CodecUnmarshal CodecDirection = iota
+	// CodecMarshal is the direction of Serialize.
+	CodecMarshal
+)
+
+func (d CodecDirection) String() string {
+	switch d {
+	case CodecUnmarshal:
+		return "unmarshal"
+	case CodecMarshal:
+		return "marshal"
+	default:
+		return fmt.Sprintf("CodecDirection(%d)", int(d))
+	}
+}
+
+// CodecError is returned by CreateType and Serialize when a codec can't be
+// found or fails. Its message is that of Err, and errors.Is and errors.As see
+// through it.
+type CodecError struct {
+	// Key is the codec key: the one passed to CreateType, or the registered
+	// key of the value's codec. Nil if Serialize found no codec.
+	Key any
+	// Type is the Go type being created or serialized. Nil if CreateType
+	// found no codec, or for a nil value.
+	Type reflect.Type
+	// DataType is the requested wire format type.
+	DataType reflect.Type
+	// Direction is the codec half involved.
+	Direction CodecDirection
+	// Err is the underlying error, e.g. wrapping ErrFactoryNotFound or
+	// returned by the codec.
+	Err error
+}
+
+func (e *CodecError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CodecError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,180 @@
+package typemux_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/struct0x/typemux"
+)
+
+func TestDispatchError(t *testing.T) {
+	reg := typemux.NewRegistry()
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error { return errDeadlock })
+
+	for name, disp := range map[string]func(v any, mw ...typemux.DispatchMiddleware) error{
+		"standard_registry": func(v any, mw ...typemux.DispatchMiddleware) error {
+			return typemux.Dispatch(reg, context.Background(), v, mw...)
+		},
+		"sealed_registry": func(v any, mw ...typemux.DispatchMiddleware) error {
+			return typemux.Dispatch(reg.Seal(), context.Background(), v, mw...)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cases := []struct {
+				name  string
+				value any
+				mw    []typemux.DispatchMiddleware
+				phase typemux.DispatchPhase
+				is    error
+				msg   string
+			}{
+				{
+					name:  "lookup",
+					value: OrderPlaced{},
+					phase: typemux.PhaseLookup,
+					is:    typemux.ErrHandlerNotFound,
+					msg:   "typemux: handler not found for type typemux_test.OrderPlaced",
+				},
+				{
+					name:  "handler",
+					value: testEvent{},
+					phase: typemux.PhaseHandler,
+					is:    errDeadlock,
+					msg:   "deadlock detected",
+				},
+				{
+					name:  "passed_through",
+					value: testEvent{},
+					mw: []typemux.DispatchMiddleware{func(ctx context.Context, event any, next func(context.Context) error) error {
+						return next(ctx)
+					}},
+					phase: typemux.PhaseHandler,
+					is:    errDeadlock,
+					msg:   "deadlock detected",
+				},
+				{
+					name:  "wrapped_handler",
+					value: testEvent{},
+					mw:    []typemux.DispatchMiddleware{typemux.RetryDispatch(typemux.RetryConfig{MaxAttempts: 1})},
+					phase: typemux.PhaseHandler,
+					is:    errDeadlock,
+					msg:   "typemux: giving up after 1 attempts: deadlock detected",
+				},
+				{
+					name:  "wrapped_lookup",
+					value: OrderPlaced{},
+					mw: []typemux.DispatchMiddleware{func(ctx context.Context, event any, next func(context.Context) error) error {
+						return fmt.Errorf("wrapped: %w", next(ctx))
+					}},
+					phase: typemux.PhaseLookup,
+					is:    typemux.ErrHandlerNotFound,
+					msg:   "wrapped: typemux: handler not found for type typemux_test.OrderPlaced",
+				},
+				{
+					name:  "middleware",
+					value: testEvent{},
+					mw: []typemux.DispatchMiddleware{func(ctx context.Context, event any, next func(context.Context) error) error {
+						_ = next(ctx)
+						return errCorrupt
+					}},
+					phase: typemux.PhaseMiddleware,
+					is:    errCorrupt,
+					msg:   "corrupt state",
+				},
+			}
+			for _, tc := range cases {
+				t.Run(tc.name, func(t *testing.T) {
+					err := disp(tc.value, tc.mw...)
+
+					var de *typemux.DispatchError
+					if !errors.As(err, &de) {
+						t.Fatalf("expected *DispatchError, got %v", err)
+					}
+					if de.Type != reflect.TypeOf(tc.value) || de.Phase != tc.phase {
+						t.Errorf("expected %v in phase %v, got %v in phase %v", reflect.TypeOf(tc.value), tc.phase, de.Type, de.Phase)
+					}
+					if !errors.Is(err, tc.is) {
+						t.Errorf("expected %v to match %v", err, tc.is)
+					}
+					if err.Error() != tc.msg {
+						t.Errorf("expected %q, got %q", tc.msg, err.Error())
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestCodecError(t *testing.T) {
+	reg := typemux.NewRegistry()
+	typemux.RegisterCodec(reg, "order_placed", typemux.JSONCodec[OrderPlaced]())
+	typemux.RegisterCodec(reg, "user_created", readOnly(func(data []byte) (UserCreated, error) {
+		return UserCreated{}, errCorrupt
+	}))
+
+	bytesType := reflect.TypeOf([]byte(nil))
+	stringType := reflect.TypeOf("")
+
+	cases := []struct {
+		name string
+		call func() error
+		want typemux.CodecError
+		is   error
+	}{
+		{
+			name: "factory_not_found",
+			call: func() error { _, err := typemux.CreateType(reg, "missing", []byte("{}")); return err },
+			want: typemux.CodecError{Key: "missing", DataType: bytesType, Direction: typemux.CodecUnmarshal},
+			is:   typemux.ErrFactoryNotFound,
+		},
+		{
+			name: "data_type_not_supported",
+			call: func() error { _, err := typemux.CreateType(reg, "order_placed", "{}"); return err },
+			want: typemux.CodecError{Key: "order_placed", DataType: stringType, Direction: typemux.CodecUnmarshal},
+			is:   typemux.ErrDataTypeNotSupported,
+		},
+		{
+			name: "unmarshal",
+			call: func() error { _, err := typemux.CreateType(reg, "user_created", []byte("{}")); return err },
+			want: typemux.CodecError{Key: "user_created", Type: reflect.TypeOf(UserCreated{}), DataType: bytesType, Direction: typemux.CodecUnmarshal},
+			is:   errCorrupt,
+		},
+		{
+			name: "serializer_not_found",
+			call: func() error { _, _, err := typemux.Serialize[string, []byte](reg, testEvent{}); return err },
+			want: typemux.CodecError{Type: reflect.TypeOf(testEvent{}), DataType: bytesType, Direction: typemux.CodecMarshal},
+			is:   typemux.ErrSerializerNotFound,
+		},
+		{
+			name: "key_type_mismatch",
+			call: func() error { _, _, err := typemux.Serialize[int, []byte](reg, OrderPlaced{}); return err },
+			want: typemux.CodecError{Key: "order_placed", Type: reflect.TypeOf(OrderPlaced{}), DataType: bytesType, Direction: typemux.CodecMarshal},
+			is:   typemux.ErrKeyTypeMismatch,
+		},
+		{
+			name: "unsupported",
+			call: func() error { _, _, err := typemux.Serialize[string, []byte](reg, UserCreated{}); return err },
+			want: typemux.CodecError{Key: "user_created", Type: reflect.TypeOf(UserCreated{}), DataType: bytesType, Direction: typemux.CodecMarshal},
+			is:   typemux.ErrUnsupported,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.call()
+
+			var ce *typemux.CodecError
+			if !errors.As(err, &ce) {
+				t.Fatalf("expected *CodecError, got %v", err)
+			}
+			if ce.Key != tc.want.Key || ce.Type != tc.want.Type || ce.DataType != tc.want.DataType || ce.Direction != tc.want.Direction {
+				t.Errorf("expected %+v, got %+v", tc.want, *ce)
+			}
+			if !errors.Is(err, tc.is) {
+				t.Errorf("expected %v to match %v", err, tc.is)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package typemux
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
@@ -14,7 +15,7 @@ var ErrFactoryNotFound = errors.New("factory not found")
 // key, but none of them accept the DATA type passed to CreateType.
 var ErrDataTypeNotSupported = errors.New("data type not supported")
 
-type factoryFuncAny func(data any) (any, error)
+type factoryFuncAny func(ctx context.Context, data any) (any, error)
 
 type factoryResolver interface {
 	getFactory(key any, dataType reflect.Type) (factoryFuncAny, bool)
@@ -28,14 +29,23 @@ type factoryResolver interface {
 //   - ErrFactoryNotFound if no codec is registered under the key at all
 //   - ErrDataTypeNotSupported if the key has codecs but none accepting DATA
 //   - ErrUnsupported if the codec's unmarshal half was Unsupported
+//
+// Every error is a *CodecError in the CodecUnmarshal direction.
 func CreateType[KEY comparable, DATA any](reg factoryResolver, key KEY, data DATA) (any, error) {
+	return CreateTypeContext(reg, context.Background(), key, data)
+}
+
+// CreateTypeContext is CreateType with a context, passed on to the codec
+// middleware (see WithCodecMiddleware), e.g. to parent its trace spans.
+func CreateTypeContext[KEY comparable, DATA any](reg factoryResolver, ctx context.Context, key KEY, data DATA) (any, error) {
 	dataType := reflect.TypeOf((*DATA)(nil)).Elem()
 	factory, ok := reg.getFactory(key, dataType)
 	if !ok {
+		err := fmt.Errorf("typemux: %w for key %v", ErrFactoryNotFound, key)
 		if reg.keyRegistered(key) {
-			return nil, fmt.Errorf("typemux: %w: key %v has no factory accepting %v", ErrDataTypeNotSupported, key, dataType)
+			err = fmt.Errorf("typemux: %w: key %v has no factory accepting %v", ErrDataTypeNotSupported, key, dataType)
 		}
-		return nil, fmt.Errorf("typemux: %w for key %v", ErrFactoryNotFound, key)
+		return nil, &CodecError{Key: key, DataType: dataType, Direction: CodecUnmarshal, Err: err}
 	}
-	return factory(data)
+	return factory(ctx, data)
 }
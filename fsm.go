@@ -0,0 +1,297 @@
+package typemux
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"sync"
+)
+
+// ErrInvalidTransition is returned by Fire when no transition is registered
+// for the state and event types.
+var ErrInvalidTransition = errors.New("invalid transition")
+
+// TransitionFunc computes the next state of type To from a state of type
+// From and an event of type E.
+type TransitionFunc[From, E, To any] func(ctx context.Context, state From, event E) (To, error)
+
+// Guard decides whether a transition may fire. A non-nil error vetoes the
+// transition and is returned by Fire.
+type Guard[From, E any] func(ctx context.Context, state From, event E) error
+
+// StateHook runs when a state of type S is entered or exited.
+type StateHook[S any] func(ctx context.Context, state S) error
+
+// Transition describes one registered transition of an FSM.
+type Transition struct {
+	From  reflect.Type
+	Event reflect.Type
+	To    reflect.Type
+	// Guarded reports whether the transition has guards.
+	Guarded bool
+}
+
+type transitionKey struct {
+	from  reflect.Type
+	event reflect.Type
+}
+
+type transitionEntry struct {
+	to      reflect.Type
+	guarded bool
+	fn      func(ctx context.Context, state, event any) (any, error)
+}
+
+type stateHookAny func(ctx context.Context, state any) error
+
+type transitionRegistry interface {
+	registerTransition(key transitionKey, entry transitionEntry)
+}
+
+type hookRegistry interface {
+	registerHook(typ reflect.Type, enter bool, hook stateHookAny)
+}
+
+// fsmTable holds the transitions and hooks shared by FSM and SealedFSM.
+type fsmTable struct {
+	transitions map[transitionKey]transitionEntry
+	enter       map[reflect.Type][]stateHookAny
+	exit        map[reflect.Type][]stateHookAny
+}
+
+func newFSMTable() fsmTable {
+	return fsmTable{
+		transitions: make(map[transitionKey]transitionEntry),
+		enter:       make(map[reflect.Type][]stateHookAny),
+		exit:        make(map[reflect.Type][]stateHookAny),
+	}
+}
+
+func (t *fsmTable) clone() fsmTable {
+	return fsmTable{
+		transitions: maps.Clone(t.transitions),
+		enter:       maps.Clone(t.enter),
+		exit:        maps.Clone(t.exit),
+	}
+}
+
+func (t *fsmTable) hooks(typ reflect.Type, enter bool) []stateHookAny {
+	if enter {
+		return t.enter[typ]
+	}
+	return t.exit[typ]
+}
+
+func (t *fsmTable) table() []Transition {
+	table := make([]Transition, 0, len(t.transitions))
+	for key, e := range t.transitions {
+		table = append(table, Transition{From: key.from, Event: key.event, To: e.to, Guarded: e.guarded})
+	}
+	slices.SortFunc(table, func(a, b Transition) int {
+		if c := cmp.Compare(a.From.String(), b.From.String()); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Event.String(), b.Event.String())
+	})
+	return table
+}
+
+// FSM is a finite state machine whose states and events are Go types: the
+// transition taken is selected by the concrete types of the current state and
+// the incoming event. Use NewFSM to create one, then RegisterTransition.
+type FSM struct {
+	opts options
+
+	mu sync.RWMutex
+	fsmTable
+}
+
+// NewFSM creates a new empty FSM. It honors WithStrictDuplicates.
+func NewFSM(opts ...Option) *FSM {
+	return &FSM{opts: newOptions(opts), fsmTable: newFSMTable()}
+}
+
+func (f *FSM) registerTransition(key transitionKey, entry transitionEntry) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.transitions == nil {
+		f.transitions = make(map[transitionKey]transitionEntry)
+	}
+
+	if _, ok := f.transitions[key]; ok && f.opts.strict {
+		panic(fmt.Errorf("typemux: %w: transition from %v on %v", ErrDuplicateRegistration, key.from, key.event))
+	}
+
+	f.transitions[key] = entry
+}
+
+func (f *FSM) registerHook(typ reflect.Type, enter bool, hook stateHookAny) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	hooks := &f.exit
+	if enter {
+		hooks = &f.enter
+	}
+	if *hooks == nil {
+		*hooks = make(map[reflect.Type][]stateHookAny)
+	}
+
+	// Clip forces append to copy, so hooks read by a running Fire stay
+	// untouched by later registrations.
+	(*hooks)[typ] = append(slices.Clip((*hooks)[typ]), hook)
+}
+
+// Fire looks up the transition for the concrete types of state and event,
+// runs its guards and transition function, and returns the next state.
+//
+// When the next state has a different type than state, the exit hooks of
+// the old state type run, then the entry hooks of the new one; transitions
+// that keep the state type run no hooks. If anything fails — including a
+// guard or a hook — Fire returns state unchanged along with the error.
+//
+// It returns ErrInvalidTransition if no transition is registered for the pair
+// of types. No pointer fallback applies.
+func (f *FSM) Fire(ctx context.Context, state, event any) (any, error) {
+	return fire(f, ctx, state, event)
+}
+
+func (f *FSM) transition(key transitionKey) (transitionEntry, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	e, ok := f.transitions[key]
+	return e, ok
+}
+
+func (f *FSM) hooks(typ reflect.Type, enter bool) []stateHookAny {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.fsmTable.hooks(typ, enter)
+}
+
+// Transitions returns the transition table, ordered by state and event type
+// name.
+func (f *FSM) Transitions() []Transition {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.table()
+}
+
+// Seal finalizes the FSM and returns a SealedFSM.
+func (f *FSM) Seal() *SealedFSM {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return &SealedFSM{fsmTable: f.clone()}
+}
+
+// SealedFSM is an immutable FSM, safe for concurrent use with no mutex
+// overhead.
+type SealedFSM struct {
+	fsmTable
+}
+
+// Fire behaves like FSM.Fire.
+func (s *SealedFSM) Fire(ctx context.Context, state, event any) (any, error) {
+	return fire(s, ctx, state, event)
+}
+
+func (s *SealedFSM) transition(key transitionKey) (transitionEntry, bool) {
+	e, ok := s.transitions[key]
+	return e, ok
+}
+
+// Transitions returns the transition table, ordered by state and event type
+// name.
+func (s *SealedFSM) Transitions() []Transition {
+	return s.table()
+}
+
+type firer interface {
+	transition(key transitionKey) (transitionEntry, bool)
+	hooks(typ reflect.Type, enter bool) []stateHookAny
+}
+
+func fire(m firer, ctx context.Context, state, event any) (any, error) {
+	key := transitionKey{from: reflect.TypeOf(state), event: reflect.TypeOf(event)}
+
+	entry, ok := m.transition(key)
+	if !ok {
+		return state, fmt.Errorf("typemux: %w: no transition from %v on %v", ErrInvalidTransition, key.from, key.event)
+	}
+
+	next, err := entry.fn(ctx, state, event)
+	if err != nil {
+		return state, err
+	}
+
+	// Hooks are keyed by the dynamic type of the next state, so a
+	// transition declared with an interface To still reaches them.
+	nextType := reflect.TypeOf(next)
+	if nextType == key.from {
+		return next, nil
+	}
+	for _, hook := range m.hooks(key.from, false) {
+		if err := hook(ctx, state); err != nil {
+			return state, err
+		}
+	}
+	for _, hook := range m.hooks(nextType, true) {
+		if err := hook(ctx, next); err != nil {
+			return state, err
+		}
+	}
+	return next, nil
+}
+
+// RegisterTransition adds the transition taken when an event of type E
+// arrives in a state of type From, with optional guards. Guards run in
+// order before the transition function; the first error vetoes it.
+//
+// If a transition for the same (From, E) has already been registered, it
+// will be replaced, unless the FSM is in strict mode.
+func RegisterTransition[From, E, To any](fsm transitionRegistry, transition TransitionFunc[From, E, To], guards ...Guard[From, E]) {
+	from := reflect.TypeOf((*From)(nil)).Elem()
+	event := reflect.TypeOf((*E)(nil)).Elem()
+	to := reflect.TypeOf((*To)(nil)).Elem()
+
+	fsm.registerTransition(transitionKey{from: from, event: event}, transitionEntry{
+		to:      to,
+		guarded: len(guards) > 0,
+		fn: func(ctx context.Context, state, ev any) (any, error) {
+			s, e := state.(From), ev.(E)
+			for _, guard := range guards {
+				if err := guard(ctx, s, e); err != nil {
+					return nil, err
+				}
+			}
+			return transition(ctx, s, e)
+		},
+	})
+}
+
+// OnEnter adds a hook run whenever Fire moves into a state of type S.
+// Hooks accumulate and run in registration order.
+func OnEnter[S any](fsm hookRegistry, hook StateHook[S]) {
+	fsm.registerHook(reflect.TypeOf((*S)(nil)).Elem(), true, wrapStateHook(hook))
+}
+
+// OnExit adds a hook run whenever Fire moves out of a state of type S.
+// Hooks accumulate and run in registration order.
+func OnExit[S any](fsm hookRegistry, hook StateHook[S]) {
+	fsm.registerHook(reflect.TypeOf((*S)(nil)).Elem(), false, wrapStateHook(hook))
+}
+
+func wrapStateHook[S any](hook StateHook[S]) stateHookAny {
+	return func(ctx context.Context, state any) error {
+		return hook(ctx, state.(S))
+	}
+}
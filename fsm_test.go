@@ -0,0 +1,176 @@
+package typemux_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/struct0x/typemux"
+)
+
+type (
+	draft     struct{ Title string }
+	inReview  struct{ Title, Reviewer string }
+	published struct{ Title string }
+)
+
+type (
+	submit  struct{ Reviewer string }
+	approve struct{}
+	reject  struct{ Reason string }
+	retitle struct{ Title string }
+)
+
+var errNoReviewer = errors.New("no reviewer")
+
+func newDocumentFSM(log *[]string) *typemux.FSM {
+	fsm := typemux.NewFSM()
+
+	typemux.RegisterTransition(fsm, func(ctx context.Context, s draft, e submit) (inReview, error) {
+		return inReview{Title: s.Title, Reviewer: e.Reviewer}, nil
+	}, func(ctx context.Context, s draft, e submit) error {
+		if e.Reviewer == "" {
+			return errNoReviewer
+		}
+		return nil
+	})
+	typemux.RegisterTransition(fsm, func(ctx context.Context, s draft, e retitle) (draft, error) {
+		return draft{Title: e.Title}, nil
+	})
+	typemux.RegisterTransition(fsm, func(ctx context.Context, s inReview, e approve) (published, error) {
+		return published{Title: s.Title}, nil
+	})
+	typemux.RegisterTransition(fsm, func(ctx context.Context, s inReview, e reject) (draft, error) {
+		return draft{Title: s.Title}, nil
+	})
+
+	typemux.OnExit(fsm, func(ctx context.Context, s draft) error {
+		*log = append(*log, "exit draft")
+		return nil
+	})
+	typemux.OnEnter(fsm, func(ctx context.Context, s inReview) error {
+		*log = append(*log, "enter review by "+s.Reviewer)
+		return nil
+	})
+	typemux.OnEnter(fsm, func(ctx context.Context, s published) error {
+		*log = append(*log, "enter published")
+		return nil
+	})
+	return fsm
+}
+
+type firer interface {
+	Fire(ctx context.Context, state, event any) (any, error)
+}
+
+func TestFSM_Fire(t *testing.T) {
+	var log []string
+	fsm := newDocumentFSM(&log)
+
+	for name, m := range map[string]firer{
+		"standard_fsm": fsm,
+		"sealed_fsm":   fsm.Seal(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			log = nil
+			ctx := context.Background()
+
+			var state any = draft{Title: "v1"}
+			for _, event := range []any{retitle{Title: "v2"}, submit{Reviewer: "bob"}, approve{}} {
+				next, err := m.Fire(ctx, state, event)
+				if err != nil {
+					t.Fatalf("unexpected error on %T: %v", event, err)
+				}
+				state = next
+			}
+
+			if want := (published{Title: "v2"}); state != want {
+				t.Errorf("expected %+v, got %+v", want, state)
+			}
+			// retitle keeps the state type, so it runs no hooks.
+			want := []string{"exit draft", "enter review by bob", "enter published"}
+			if !reflect.DeepEqual(log, want) {
+				t.Errorf("expected hooks %v, got %v", want, log)
+			}
+		})
+	}
+}
+
+func TestFSM_Errors(t *testing.T) {
+	var log []string
+	fsm := newDocumentFSM(&log)
+	ctx := context.Background()
+
+	t.Run("invalid_transition", func(t *testing.T) {
+		state := published{Title: "v1"}
+		got, err := fsm.Fire(ctx, state, submit{Reviewer: "bob"})
+		if !errors.Is(err, typemux.ErrInvalidTransition) {
+			t.Fatalf("expected ErrInvalidTransition, got %v", err)
+		}
+		const want = "typemux: invalid transition: no transition from typemux_test.published on typemux_test.submit"
+		if err.Error() != want {
+			t.Errorf("expected %q, got %q", want, err.Error())
+		}
+		if got != state {
+			t.Errorf("expected state unchanged, got %+v", got)
+		}
+	})
+
+	t.Run("guard", func(t *testing.T) {
+		log = nil
+		state := draft{Title: "v1"}
+		got, err := fsm.Seal().Fire(ctx, state, submit{})
+		if !errors.Is(err, errNoReviewer) {
+			t.Fatalf("expected guard error, got %v", err)
+		}
+		if got != state || len(log) != 0 {
+			t.Errorf("expected state unchanged and no hooks, got %+v, %v", got, log)
+		}
+	})
+
+	t.Run("hook", func(t *testing.T) {
+		errBusy := errors.New("reviewer busy")
+		typemux.OnEnter(fsm, func(ctx context.Context, s inReview) error { return errBusy })
+
+		state := draft{Title: "v1"}
+		got, err := fsm.Fire(ctx, state, submit{Reviewer: "bob"})
+		if !errors.Is(err, errBusy) {
+			t.Fatalf("expected hook error, got %v", err)
+		}
+		if got != state {
+			t.Errorf("expected state unchanged, got %+v", got)
+		}
+	})
+}
+
+func TestFSM_Transitions(t *testing.T) {
+	fsm := newDocumentFSM(new([]string))
+
+	typeOf := func(v any) reflect.Type { return reflect.TypeOf(v) }
+	want := []typemux.Transition{
+		{From: typeOf(draft{}), Event: typeOf(retitle{}), To: typeOf(draft{})},
+		{From: typeOf(draft{}), Event: typeOf(submit{}), To: typeOf(inReview{}), Guarded: true},
+		{From: typeOf(inReview{}), Event: typeOf(approve{}), To: typeOf(published{})},
+		{From: typeOf(inReview{}), Event: typeOf(reject{}), To: typeOf(draft{})},
+	}
+
+	if got := fsm.Transitions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if got := fsm.Seal().Transitions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected sealed table %v, got %v", want, got)
+	}
+}
+
+func TestFSM_StrictDuplicates(t *testing.T) {
+	fsm := typemux.NewFSM(typemux.WithStrictDuplicates())
+	approveDraft := func(ctx context.Context, s draft, e approve) (published, error) {
+		return published{Title: s.Title}, nil
+	}
+	typemux.RegisterTransition(fsm, approveDraft)
+
+	expectDuplicatePanic(t, "draft", func() {
+		typemux.RegisterTransition(fsm, approveDraft)
+	})
+}
@@ -0,0 +1,242 @@
+package typemux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned by limit middleware when no token is available:
+// right away under LimitReject, or before the context's deadline under
+// LimitWait.
+var ErrRateLimited = errors.New("rate limited")
+
+// ErrConcurrencyLimited is returned by limit middleware under LimitReject when
+// the maximum number of calls is already in flight.
+var ErrConcurrencyLimited = errors.New("concurrency limited")
+
+// LimitPolicy controls what a Limiter does when a call is over its limits.
+type LimitPolicy int
+
+const (
+	// LimitWait makes the call wait for a token and an in-flight slot, until
+	// its context is done. A token that can't arrive before the context's
+	// deadline is not waited for.
+	LimitWait LimitPolicy = iota
+	// LimitReject fails the call immediately.
+	LimitReject
+)
+
+// LimitConfig configures a Limiter.
+type LimitConfig struct {
+	// Rate is the number of calls per second allowed on average. Zero
+	// disables rate limiting.
+	Rate float64
+	// Burst is the number of calls allowed at once when tokens have built up.
+	// Defaults to Rate rounded up, and at least 1.
+	Burst int
+	// MaxInFlight is the number of calls allowed to run concurrently. Zero
+	// means unlimited.
+	MaxInFlight int
+	// Policy decides between waiting and rejecting.
+	Policy LimitPolicy
+	// Clock is used to refill tokens and wait for them. Defaults to the
+	// system clock.
+	Clock Clock
+}
+
+// Limiter enforces a token-bucket rate limit and a maximum number of calls in
+// flight. Every type guarded by the same Limiter shares its limits; use one
+// Limiter per type for independent limits.
+type Limiter struct {
+	rate   float64
+	burst  float64
+	policy LimitPolicy
+	clock  Clock
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+
+	// slots is a semaphore of MaxInFlight; nil when unlimited.
+	slots chan struct{}
+}
+
+// NewLimiter creates a Limiter with a full token bucket.
+func NewLimiter(cfg LimitConfig) *Limiter {
+	l := &Limiter{
+		rate:   cfg.Rate,
+		burst:  float64(cfg.Burst),
+		policy: cfg.Policy,
+		clock:  clockOrSystem(cfg.Clock),
+	}
+	if l.burst <= 0 {
+		l.burst = max(1, math.Ceil(cfg.Rate))
+	}
+	l.tokens = l.burst
+	l.last = l.clock.Now()
+	if cfg.MaxInFlight > 0 {
+		l.slots = make(chan struct{}, cfg.MaxInFlight)
+	}
+	return l
+}
+
+// Middleware returns a DispatchMiddleware guarding every dispatch with l.
+func (l *Limiter) Middleware() DispatchMiddleware {
+	return func(ctx context.Context, event any, next func(context.Context) error) error {
+		return l.do(reflect.TypeOf(event), ctx, next)
+	}
+}
+
+// Limit returns a typed Middleware guarding the handler for T with l.
+func Limit[T any](l *Limiter) Middleware[T] {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	return func(next HandlerFunc[T]) HandlerFunc[T] {
+		return func(ctx context.Context, val T) error {
+			return l.do(typ, ctx, func(ctx context.Context) error {
+				return next(ctx, val)
+			})
+		}
+	}
+}
+
+// LimitByType returns a DispatchMiddleware keeping an independent Limiter,
+// built from cfg, for each concrete type dispatched through it.
+func LimitByType(cfg LimitConfig) DispatchMiddleware {
+	var limiters sync.Map // reflect.Type -> *Limiter
+	return func(ctx context.Context, event any, next func(context.Context) error) error {
+		typ := reflect.TypeOf(event)
+		l, ok := limiters.Load(typ)
+		if !ok {
+			l, _ = limiters.LoadOrStore(typ, NewLimiter(cfg))
+		}
+		return l.(*Limiter).do(typ, ctx, next)
+	}
+}
+
+// WithLimit guards the dispatch handler for T with l, whenever it is
+// registered. Pass the same Limiter for several types to share it across the
+// group. Limits are carried over by Seal and inherited by children, but not
+// applied to subscribers, queries or batch handlers.
+func WithLimit[T any](l *Limiter) Option {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	return func(o *options) {
+		if o.limits == nil {
+			o.limits = make(map[reflect.Type]*Limiter)
+		}
+		o.limits[typ] = l
+	}
+}
+
+// limit wraps handler in the limiter configured for typ, if any.
+func (o *options) limit(typ reflect.Type, handler handlerFuncAny) handlerFuncAny {
+	l, ok := o.limits[typ]
+	if !ok {
+		return handler
+	}
+	return func(ctx context.Context, v any) error {
+		return l.do(typ, ctx, func(ctx context.Context) error {
+			return handler(ctx, v)
+		})
+	}
+}
+
+func (l *Limiter) do(typ reflect.Type, ctx context.Context, call func(context.Context) error) error {
+	if err := l.acquireSlot(ctx); err != nil {
+		return fmt.Errorf("typemux: %w for type %v", err, typ)
+	}
+	defer l.releaseSlot()
+
+	if err := l.acquireToken(ctx); err != nil {
+		return fmt.Errorf("typemux: %w for type %v", err, typ)
+	}
+	return call(ctx)
+}
+
+func (l *Limiter) acquireSlot(ctx context.Context) error {
+	if l.slots == nil {
+		return nil
+	}
+
+	if l.policy == LimitReject {
+		select {
+		case l.slots <- struct{}{}:
+			return nil
+		default:
+			return ErrConcurrencyLimited
+		}
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *Limiter) releaseSlot() {
+	if l.slots != nil {
+		<-l.slots
+	}
+}
+
+func (l *Limiter) acquireToken(ctx context.Context) error {
+	if l.rate <= 0 {
+		return nil
+	}
+
+	wait, ok := l.reserve(ctx)
+	if !ok {
+		return ErrRateLimited
+	}
+	if wait <= 0 {
+		return nil
+	}
+
+	select {
+	case <-l.clock.After(wait):
+		return nil
+	case <-ctx.Done():
+		l.unreserve()
+		return ctx.Err()
+	}
+}
+
+// reserve takes a token, possibly ahead of time, and returns how long to wait
+// for it to become due. It takes nothing and reports false if the policy or
+// the context's deadline rules out the wait.
+func (l *Limiter) reserve(ctx context.Context) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0, true
+	}
+	if l.policy == LimitReject {
+		return 0, false
+	}
+
+	wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+	if deadline, ok := ctx.Deadline(); ok && now.Add(wait).After(deadline) {
+		return 0, false
+	}
+	l.tokens--
+	return wait, true
+}
+
+func (l *Limiter) unreserve() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens = min(l.burst, l.tokens+1)
+}
@@ -0,0 +1,164 @@
+package typemux_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/struct0x/typemux"
+)
+
+func TestLimiter_RateReject(t *testing.T) {
+	clock := newFakeClock()
+	l := typemux.NewLimiter(typemux.LimitConfig{Rate: 1, Burst: 2, Policy: typemux.LimitReject, Clock: clock})
+
+	reg := typemux.NewRegistry()
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error { return nil }, typemux.Limit[testEvent](l))
+
+	for i := range 2 {
+		if err := typemux.Dispatch(reg, context.Background(), testEvent{}); err != nil {
+			t.Fatalf("call %d: unexpected error: %v", i, err)
+		}
+	}
+
+	err := typemux.Dispatch(reg, context.Background(), testEvent{})
+	if !errors.Is(err, typemux.ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+	const want = "typemux: rate limited for type typemux_test.testEvent"
+	if err.Error() != want {
+		t.Errorf("expected %q, got %q", want, err.Error())
+	}
+
+	clock.Advance(time.Second)
+	if err := typemux.Dispatch(reg, context.Background(), testEvent{}); err != nil {
+		t.Errorf("expected a refilled token, got %v", err)
+	}
+}
+
+func TestLimiter_RateWait(t *testing.T) {
+	clock := newFakeClock()
+	l := typemux.NewLimiter(typemux.LimitConfig{Rate: 4, Burst: 1, Clock: clock})
+	mw := l.Middleware()
+
+	for range 3 {
+		if err := mw(context.Background(), testEvent{}, func(context.Context) error { return nil }); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	want := []time.Duration{250 * time.Millisecond, 250 * time.Millisecond}
+	if got := clock.Waits(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected waits %v, got %v", want, got)
+	}
+}
+
+func TestLimiter_RateWaitHonorsDeadline(t *testing.T) {
+	l := typemux.NewLimiter(typemux.LimitConfig{Rate: 1, Burst: 1})
+	handler := typemux.Limit[testEvent](l)(func(ctx context.Context, e testEvent) error { return nil })
+
+	if err := handler(context.Background(), testEvent{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// Waiting would end in DeadlineExceeded; the token can't arrive in time,
+	// so the call is rejected up front.
+	if err := handler(ctx, testEvent{}); !errors.Is(err, typemux.ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+}
+
+func TestLimiter_MaxInFlight(t *testing.T) {
+	for _, policy := range []typemux.LimitPolicy{typemux.LimitReject, typemux.LimitWait} {
+		l := typemux.NewLimiter(typemux.LimitConfig{MaxInFlight: 1, Policy: policy})
+
+		entered := make(chan struct{})
+		release := make(chan struct{})
+		handler := typemux.Limit[testEvent](l)(func(ctx context.Context, e testEvent) error {
+			if e.Name == "block" {
+				close(entered)
+				<-release
+			}
+			return nil
+		})
+
+		done := make(chan error)
+		go func() { done <- handler(context.Background(), testEvent{Name: "block"}) }()
+		<-entered
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		err := handler(ctx, testEvent{})
+		cancel()
+
+		switch policy {
+		case typemux.LimitReject:
+			if !errors.Is(err, typemux.ErrConcurrencyLimited) {
+				t.Errorf("reject: expected ErrConcurrencyLimited, got %v", err)
+			}
+		case typemux.LimitWait:
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("wait: expected the wait to end with the context, got %v", err)
+			}
+		}
+
+		close(release)
+		if err := <-done; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := handler(context.Background(), testEvent{}); err != nil {
+			t.Errorf("expected the slot to be released, got %v", err)
+		}
+	}
+}
+
+func TestWithLimit_SharedGroup(t *testing.T) {
+	clock := newFakeClock()
+	payments := typemux.NewLimiter(typemux.LimitConfig{Rate: 1, Burst: 1, Policy: typemux.LimitReject, Clock: clock})
+
+	reg := typemux.NewRegistry(
+		typemux.WithLimit[UserCreated](payments),
+		typemux.WithLimit[OrderPlaced](payments),
+	)
+	typemux.RegisterDispatch(reg, func(ctx context.Context, u UserCreated) error { return nil })
+	typemux.RegisterDispatch(reg, func(ctx context.Context, o OrderPlaced) error { return nil })
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error { return nil })
+
+	for name, disp := range map[string]func(v any) error{
+		"standard_registry": func(v any) error { return typemux.Dispatch(reg, context.Background(), v) },
+		"sealed_registry":   func(v any) error { return typemux.Dispatch(reg.Seal(), context.Background(), v) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			clock.Advance(time.Second)
+
+			if err := disp(UserCreated{}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := disp(OrderPlaced{}); !errors.Is(err, typemux.ErrRateLimited) {
+				t.Errorf("expected the group's token to be used up, got %v", err)
+			}
+			if err := disp(testEvent{}); err != nil {
+				t.Errorf("expected types outside the group unaffected, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLimitByType(t *testing.T) {
+	mw := typemux.LimitByType(typemux.LimitConfig{Rate: 1, Burst: 1, Policy: typemux.LimitReject, Clock: newFakeClock()})
+	next := func(context.Context) error { return nil }
+
+	if err := mw(context.Background(), UserCreated{}, next); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mw(context.Background(), OrderPlaced{}, next); err != nil {
+		t.Errorf("expected an independent limiter per type, got %v", err)
+	}
+	if err := mw(context.Background(), UserCreated{}, next); !errors.Is(err, typemux.ErrRateLimited) {
+		t.Errorf("expected ErrRateLimited, got %v", err)
+	}
+}
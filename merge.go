@@ -0,0 +1,441 @@
+package typemux
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+)
+
+// ErrMergeConflict is returned by Merge under MergeError when a source
+// registers a slot that is already taken in the destination or by an earlier
+// source.
+var ErrMergeConflict = errors.New("merge conflict")
+
+// MergePolicy controls how Merge resolves conflicting registrations.
+type MergePolicy int
+
+const (
+	// MergeError fails the merge on any conflict, leaving the destination
+	// untouched.
+	MergeError MergePolicy = iota
+	// MergeKeepFirst keeps the registration that came first: the
+	// destination's own, then the sources' in argument order.
+	MergeKeepFirst
+	// MergeKeepLast lets each source replace what came before it.
+	MergeKeepLast
+)
+
+// MergeKind names the kind of registration a MergeEntry describes.
+type MergeKind int
+
+const (
+	// MergeHandler is a dispatch handler, keyed by Type.
+	MergeHandler MergeKind = iota
+	// MergeFallback is the fallback handler.
+	MergeFallback
+	// MergeSubscriber is a subscriber of Type. Subscribers accumulate and
+	// never conflict.
+	MergeSubscriber
+	// MergeQuery is a query handler, keyed by its request Type.
+	MergeQuery
+	// MergeBatch is a batch handler, keyed by Type.
+	MergeBatch
+	// MergeFactory is the unmarshal half of a codec, keyed by Key and DataType.
+	MergeFactory
+	// MergeSerializer is the marshal half of a codec, keyed by Type and DataType.
+	MergeSerializer
+)
+
+func (k MergeKind) String() string {
+	switch k {
+	case MergeHandler:
+		return "handler"
+	case MergeFallback:
+		return "fallback handler"
+	case MergeSubscriber:
+		return "subscriber"
+	case MergeQuery:
+		return "query handler"
+	case MergeBatch:
+		return "batch handler"
+	case MergeFactory:
+		return "factory"
+	case MergeSerializer:
+		return "serializer"
+	default:
+		return fmt.Sprintf("MergeKind(%d)", int(k))
+	}
+}
+
+// MergeEntry describes one registration taken from a Merge source.
+type MergeEntry struct {
+	Kind MergeKind
+	// Type is the handled, requested or serialized type; unset for
+	// MergeFallback and MergeFactory.
+	Type reflect.Type
+	// Key is the factory key of a MergeFactory.
+	Key any
+	// DataType is the wire format of a MergeFactory or MergeSerializer.
+	DataType reflect.Type
+	// Source is the index of the source in the srcs given to Merge.
+	Source int
+}
+
+func (e MergeEntry) String() string {
+	var s string
+	switch e.Kind {
+	case MergeFallback:
+		s = e.Kind.String()
+	case MergeFactory:
+		s = fmt.Sprintf("%v for key %v and data %v", e.Kind, e.Key, e.DataType)
+	case MergeSerializer:
+		s = fmt.Sprintf("%v for type %v and data %v", e.Kind, e.Type, e.DataType)
+	default:
+		s = fmt.Sprintf("%v for type %v", e.Kind, e.Type)
+	}
+	return fmt.Sprintf("%s (source %d)", s, e.Source)
+}
+
+// MergeReport lists what Merge did, in source order.
+type MergeReport struct {
+	// Merged holds the registrations written into the destination.
+	Merged []MergeEntry
+	// Conflicts holds the registrations whose slot was already taken. Under
+	// MergeKeepLast they replaced the earlier registration and are listed in
+	// Merged too; under MergeKeepFirst they were dropped; under MergeError
+	// nothing was written.
+	Conflicts []MergeEntry
+}
+
+type mergeable[R any] interface {
+	merge(srcs []R, policy MergePolicy) (*MergeReport, error)
+}
+
+// Merge copies the registrations of srcs into dst: dispatch handlers, the
+// fallback, subscribers, queries and batch handlers of a DispatchRegistry,
+// and the factories and serializers of a CodecRegistry. A Registry merges
+// both halves.
+//
+// A conflict is a handler, query or batch handler for the same type, a
+// second fallback, a factory for the same (key, DATA) or a serializer for the
+// same (T, DATA), whether taken in dst or by an earlier source. Policy decides
+// how conflicts resolve; under MergeError, Merge returns an error wrapping
+// ErrMergeConflict and leaves dst untouched. Subscribers accumulate. The
+// strict mode of dst is not consulted.
+//
+// Only the sources' own registrations are merged — not their parents',
+// options or registry-level middleware. Middleware attached with UseFor
+// travels with the handler, inside any attached to dst. Registrations of dst
+// itself among srcs are ignored, and a source's Registration handles never
+// affect dst.
+func Merge[R mergeable[R]](dst R, policy MergePolicy, srcs ...R) (*MergeReport, error) {
+	return dst.merge(srcs, policy)
+}
+
+// merger tracks the slots taken while planning a merge and collects the
+// writes to apply once the plan is known to succeed.
+type merger struct {
+	policy MergePolicy
+	report *MergeReport
+	taken  map[any]bool
+	writes []func()
+}
+
+func newMerger(policy MergePolicy) *merger {
+	return &merger{policy: policy, report: &MergeReport{}, taken: make(map[any]bool)}
+}
+
+// offer plans writing entry into slot. exists reports whether the
+// destination already has the slot.
+func (m *merger) offer(entry MergeEntry, slot any, exists bool, write func()) {
+	if exists || m.taken[slot] {
+		m.report.Conflicts = append(m.report.Conflicts, entry)
+		if m.policy != MergeKeepLast {
+			return
+		}
+	}
+	m.taken[slot] = true
+	m.report.Merged = append(m.report.Merged, entry)
+	m.writes = append(m.writes, write)
+}
+
+// commit applies the planned writes, or fails under MergeError if there
+// were conflicts.
+func (m *merger) commit() (*MergeReport, error) {
+	if m.policy == MergeError && len(m.report.Conflicts) > 0 {
+		m.report.Merged = nil
+		return m.report, fmt.Errorf("typemux: %w: %d conflicting registrations, first %v",
+			ErrMergeConflict, len(m.report.Conflicts), m.report.Conflicts[0])
+	}
+
+	for _, write := range m.writes {
+		write()
+	}
+	return m.report, nil
+}
+
+// Slot keys of the dispatch side; distinct types keep a handler and a query
+// for the same type apart.
+type (
+	handlerSlot  struct{ typ reflect.Type }
+	fallbackSlot struct{}
+	querySlot    struct{ typ reflect.Type }
+	batchSlot    struct{ typ reflect.Type }
+)
+
+// dispatchSnapshot is a copy of a source's own dispatch registrations.
+type dispatchSnapshot struct {
+	builders map[reflect.Type]handlerBuilder
+	fallback handlerFuncAny
+	subs     map[reflect.Type][]handlerFuncAny
+	queries  map[reflect.Type]queryEntry
+	batches  map[reflect.Type]batchFuncAny
+}
+
+func (r *DispatchRegistry) snapshot() dispatchSnapshot {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s := dispatchSnapshot{
+		builders: make(map[reflect.Type]handlerBuilder, len(r.builders)),
+		fallback: r.fallback,
+		subs:     maps.Clone(r.subs),
+		queries:  maps.Clone(r.queries),
+		batches:  maps.Clone(r.batches),
+	}
+	for typ, build := range r.builders {
+		// Keep the source's attached middleware inside the destination's.
+		own := r.attached[typ]
+		s.builders[typ] = func(attached []any) handlerFuncAny {
+			return build(append(slices.Clip(attached), own...))
+		}
+	}
+	return s
+}
+
+func (r *DispatchRegistry) merge(srcs []*DispatchRegistry, policy MergePolicy) (*MergeReport, error) {
+	snaps := make([]dispatchSnapshot, len(srcs))
+	for i, src := range srcs {
+		if src != r {
+			snaps[i] = src.snapshot()
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m := newMerger(policy)
+	for i, snap := range snaps {
+		r.planMerge(m, i, snap)
+	}
+	return m.commit()
+}
+
+// planMerge offers every registration of source i to m. r.mu must be held.
+func (r *DispatchRegistry) planMerge(m *merger, i int, s dispatchSnapshot) {
+	for _, typ := range sortedKeys(s.builders) {
+		build := s.builders[typ]
+		_, exists := r.h[typ]
+		m.offer(MergeEntry{Kind: MergeHandler, Type: typ, Source: i}, handlerSlot{typ}, exists, func() {
+			r.setHandler(typ, build)
+		})
+	}
+
+	if s.fallback != nil {
+		fallback := s.fallback
+		m.offer(MergeEntry{Kind: MergeFallback, Source: i}, fallbackSlot{}, r.fallback != nil, func() {
+			r.fallback = r.opts.recovering(fallback)
+		})
+	}
+
+	for _, typ := range sortedKeys(s.subs) {
+		for _, sub := range s.subs[typ] {
+			m.report.Merged = append(m.report.Merged, MergeEntry{Kind: MergeSubscriber, Type: typ, Source: i})
+			m.writes = append(m.writes, func() {
+				if r.subs == nil {
+					r.subs = make(map[reflect.Type][]handlerFuncAny)
+				}
+				r.subs[typ] = append(slices.Clip(r.subs[typ]), r.opts.recovering(sub))
+			})
+		}
+	}
+
+	for _, typ := range sortedKeys(s.queries) {
+		e := s.queries[typ]
+		_, exists := r.queries[typ]
+		m.offer(MergeEntry{Kind: MergeQuery, Type: typ, Source: i}, querySlot{typ}, exists, func() {
+			if r.queries == nil {
+				r.queries = make(map[reflect.Type]queryEntry)
+			}
+			r.queries[typ] = r.opts.recoveringQuery(e)
+		})
+	}
+
+	for _, typ := range sortedKeys(s.batches) {
+		b := s.batches[typ]
+		_, exists := r.batches[typ]
+		m.offer(MergeEntry{Kind: MergeBatch, Type: typ, Source: i}, batchSlot{typ}, exists, func() {
+			if r.batches == nil {
+				r.batches = make(map[reflect.Type]batchFuncAny)
+			}
+			r.batches[typ] = r.opts.recoveringBatch(typ, b)
+		})
+	}
+}
+
+// setHandler installs a merged handler as a fresh registration. r.mu must be
+// held.
+func (r *DispatchRegistry) setHandler(typ reflect.Type, build handlerBuilder) {
+	if r.h == nil {
+		r.h = make(map[reflect.Type]handlerFuncAny)
+	}
+	if r.ids == nil {
+		r.ids = make(map[reflect.Type]uint64)
+	}
+	if r.builders == nil {
+		r.builders = make(map[reflect.Type]handlerBuilder)
+	}
+
+	if typ.Kind() == reflect.Interface && !slices.Contains(r.ifaces, typ) {
+		r.ifaces = append(r.ifaces, typ)
+	}
+	r.h[typ] = r.build(typ, build)
+	r.builders[typ] = build
+
+	// The handler has no Registration in r; a fresh id keeps earlier ones
+	// from removing it.
+	r.nextID++
+	r.ids[typ] = r.nextID
+}
+
+// codecSnapshot is a copy of a source's own codecs.
+type codecSnapshot struct {
+	factories   map[factorySlot]factoryFuncAny
+	serializers map[serializerSlot]serializerEntry
+}
+
+func (r *CodecRegistry) snapshot() codecSnapshot {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s := codecSnapshot{
+		factories:   make(map[factorySlot]factoryFuncAny),
+		serializers: make(map[serializerSlot]serializerEntry),
+	}
+	for key, inner := range r.factories {
+		for dataType, f := range inner {
+			s.factories[factorySlot{key: key, dataType: dataType}] = f
+		}
+	}
+	for typ, inner := range r.serializers {
+		for dataType, e := range inner {
+			s.serializers[serializerSlot{typ: typ, dataType: dataType}] = e
+		}
+	}
+	return s
+}
+
+func (r *CodecRegistry) merge(srcs []*CodecRegistry, policy MergePolicy) (*MergeReport, error) {
+	snaps := make([]codecSnapshot, len(srcs))
+	for i, src := range srcs {
+		if src != r {
+			snaps[i] = src.snapshot()
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m := newMerger(policy)
+	for i, snap := range snaps {
+		r.planMerge(m, i, snap)
+	}
+	return m.commit()
+}
+
+// planMerge offers every codec half of source i to m. r.mu must be held.
+func (r *CodecRegistry) planMerge(m *merger, i int, s codecSnapshot) {
+	fslots := make([]factorySlot, 0, len(s.factories))
+	for slot := range s.factories {
+		fslots = append(fslots, slot)
+	}
+	slices.SortFunc(fslots, func(a, b factorySlot) int {
+		if c := cmp.Compare(fmt.Sprint(a.key), fmt.Sprint(b.key)); c != 0 {
+			return c
+		}
+		return compareTypes(a.dataType, b.dataType)
+	})
+
+	for _, slot := range fslots {
+		f := s.factories[slot]
+		_, exists := r.factoryIDs[slot]
+		entry := MergeEntry{Kind: MergeFactory, Key: slot.key, DataType: slot.dataType, Source: i}
+		m.offer(entry, slot, exists, func() {
+			r.setFactory(slot, f)
+		})
+	}
+
+	sslots := make([]serializerSlot, 0, len(s.serializers))
+	for slot := range s.serializers {
+		sslots = append(sslots, slot)
+	}
+	slices.SortFunc(sslots, func(a, b serializerSlot) int {
+		if c := compareTypes(a.typ, b.typ); c != 0 {
+			return c
+		}
+		return compareTypes(a.dataType, b.dataType)
+	})
+
+	for _, slot := range sslots {
+		e := s.serializers[slot]
+		_, exists := r.serializerIDs[slot]
+		entry := MergeEntry{Kind: MergeSerializer, Type: slot.typ, DataType: slot.dataType, Source: i}
+		m.offer(entry, slot, exists, func() {
+			r.setSerializer(slot, e)
+		})
+	}
+}
+
+func (r *Registry) merge(srcs []*Registry, policy MergePolicy) (*MergeReport, error) {
+	dispatchSnaps := make([]dispatchSnapshot, len(srcs))
+	codecSnaps := make([]codecSnapshot, len(srcs))
+	for i, src := range srcs {
+		if src.DispatchRegistry != r.DispatchRegistry {
+			dispatchSnaps[i] = src.DispatchRegistry.snapshot()
+		}
+		if src.CodecRegistry != r.CodecRegistry {
+			codecSnaps[i] = src.CodecRegistry.snapshot()
+		}
+	}
+
+	r.DispatchRegistry.mu.Lock()
+	defer r.DispatchRegistry.mu.Unlock()
+	r.CodecRegistry.mu.Lock()
+	defer r.CodecRegistry.mu.Unlock()
+
+	// One plan for both halves, so MergeError leaves both untouched.
+	m := newMerger(policy)
+	for i := range srcs {
+		r.DispatchRegistry.planMerge(m, i, dispatchSnaps[i])
+		r.CodecRegistry.planMerge(m, i, codecSnaps[i])
+	}
+	return m.commit()
+}
+
+// sortedKeys returns the keys of m ordered by type name, so merge reports
+// don't depend on map iteration order.
+func sortedKeys[V any](m map[reflect.Type]V) []reflect.Type {
+	keys := make([]reflect.Type, 0, len(m))
+	for typ := range m {
+		keys = append(keys, typ)
+	}
+	slices.SortFunc(keys, compareTypes)
+	return keys
+}
+
+func compareTypes(a, b reflect.Type) int {
+	return cmp.Compare(a.String(), b.String())
+}
@@ -0,0 +1,250 @@
+package typemux_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/struct0x/typemux"
+)
+
+// newModule builds a registry as an independent module would: a handler and
+// a JSON codec for OrderPlaced, tagged with name.
+func newModule(name string, out *string) *typemux.Registry {
+	reg := typemux.NewRegistry()
+	typemux.RegisterDispatch(reg, func(ctx context.Context, o OrderPlaced) error {
+		*out = name
+		return nil
+	})
+	typemux.RegisterCodec(reg, "order_placed", typemux.NewCodec(
+		func(o OrderPlaced) ([]byte, error) { return []byte(name), nil },
+		func(b []byte) (OrderPlaced, error) { return OrderPlaced{OrderID: name}, nil },
+	))
+	return reg
+}
+
+func TestMerge_Disjoint(t *testing.T) {
+	users := typemux.NewRegistry()
+	var out string
+	typemux.RegisterDispatch(users, func(ctx context.Context, u UserCreated) error {
+		out = "users"
+		return nil
+	})
+	typemux.RegisterCodec(users, "user_created", typemux.JSONCodec[UserCreated]())
+	typemux.Subscribe(users, func(ctx context.Context, u UserCreated) error { return nil })
+
+	orders := newModule("orders", &out)
+
+	app := typemux.NewRegistry()
+	rep, err := typemux.Merge(app, typemux.MergeError, users, orders)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rep.Conflicts) != 0 {
+		t.Errorf("expected no conflicts, got %v", rep.Conflicts)
+	}
+
+	userType := reflect.TypeOf(UserCreated{})
+	bytesType := reflect.TypeOf([]byte(nil))
+	expected := []typemux.MergeEntry{
+		{Kind: typemux.MergeHandler, Type: userType, Source: 0},
+		{Kind: typemux.MergeSubscriber, Type: userType, Source: 0},
+		{Kind: typemux.MergeFactory, Key: "user_created", DataType: bytesType, Source: 0},
+		{Kind: typemux.MergeSerializer, Type: userType, DataType: bytesType, Source: 0},
+	}
+	if !reflect.DeepEqual(rep.Merged[:4], expected) {
+		t.Errorf("expected %v, got %v", expected, rep.Merged[:4])
+	}
+	if len(rep.Merged) != 7 {
+		t.Errorf("expected 7 merged entries, got %d: %v", len(rep.Merged), rep.Merged)
+	}
+
+	sealed := app.Seal()
+	if err := typemux.Dispatch(sealed, context.Background(), OrderPlaced{}); err != nil || out != "orders" {
+		t.Errorf("expected orders handler, got %q, %v", out, err)
+	}
+	if err := typemux.Dispatch(app, context.Background(), UserCreated{}); err != nil || out != "users" {
+		t.Errorf("expected users handler, got %q, %v", out, err)
+	}
+	if err := typemux.Publish(app, context.Background(), UserCreated{}, typemux.PublishSequential); err != nil {
+		t.Errorf("expected merged subscriber, got %v", err)
+	}
+	if _, err := typemux.CreateType(sealed, "user_created", []byte(`{}`)); err != nil {
+		t.Errorf("expected merged factory, got %v", err)
+	}
+	if key, _, err := typemux.Serialize[string, []byte](app, OrderPlaced{}); err != nil || key != "order_placed" {
+		t.Errorf("expected merged serializer, got %q, %v", key, err)
+	}
+}
+
+func TestMerge_Conflicts(t *testing.T) {
+	orderType := reflect.TypeOf(OrderPlaced{})
+	bytesType := reflect.TypeOf([]byte(nil))
+	conflicts := []typemux.MergeEntry{
+		{Kind: typemux.MergeHandler, Type: orderType, Source: 1},
+		{Kind: typemux.MergeFactory, Key: "order_placed", DataType: bytesType, Source: 1},
+		{Kind: typemux.MergeSerializer, Type: orderType, DataType: bytesType, Source: 1},
+	}
+
+	tests := []struct {
+		name     string
+		policy   typemux.MergePolicy
+		expected string
+	}{
+		{name: "keep_first", policy: typemux.MergeKeepFirst, expected: "a"},
+		{name: "keep_last", policy: typemux.MergeKeepLast, expected: "b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out string
+			app := typemux.NewRegistry()
+
+			rep, err := typemux.Merge(app, tt.policy, newModule("a", &out), newModule("b", &out))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(rep.Conflicts, conflicts) {
+				t.Errorf("expected conflicts %v, got %v", conflicts, rep.Conflicts)
+			}
+
+			if err := typemux.Dispatch(app, context.Background(), OrderPlaced{}); err != nil || out != tt.expected {
+				t.Errorf("expected handler %q, got %q, %v", tt.expected, out, err)
+			}
+			v, err := typemux.CreateType(app, "order_placed", []byte{})
+			if err != nil || v.(OrderPlaced).OrderID != tt.expected {
+				t.Errorf("expected factory %q, got %v, %v", tt.expected, v, err)
+			}
+			_, data, err := typemux.Serialize[string, []byte](app, OrderPlaced{})
+			if err != nil || string(data) != tt.expected {
+				t.Errorf("expected serializer %q, got %q, %v", tt.expected, data, err)
+			}
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		var out string
+		app := typemux.NewRegistry()
+		typemux.RegisterDispatch(app, func(ctx context.Context, o OrderPlaced) error {
+			out = "app"
+			return nil
+		})
+		users := typemux.NewRegistry()
+		typemux.RegisterCodec(users, "user_created", typemux.JSONCodec[UserCreated]())
+
+		rep, err := typemux.Merge(app, typemux.MergeError, users, newModule("a", &out))
+		if !errors.Is(err, typemux.ErrMergeConflict) {
+			t.Fatalf("expected ErrMergeConflict, got %v", err)
+		}
+		expected := []typemux.MergeEntry{{Kind: typemux.MergeHandler, Type: orderType, Source: 1}}
+		if !reflect.DeepEqual(rep.Conflicts, expected) || len(rep.Merged) != 0 {
+			t.Errorf("expected only the handler conflict, got %+v", rep)
+		}
+
+		// Nothing was written, not even the non-conflicting codecs.
+		if _, err := typemux.CreateType(app, "user_created", []byte(`{}`)); !errors.Is(err, typemux.ErrFactoryNotFound) {
+			t.Errorf("expected ErrFactoryNotFound, got %v", err)
+		}
+		if err := typemux.Dispatch(app, context.Background(), OrderPlaced{}); err != nil || out != "app" {
+			t.Errorf("expected the original handler, got %q, %v", out, err)
+		}
+	})
+}
+
+func TestMerge_DispatchRegistry(t *testing.T) {
+	var order []string
+	record := func(s string) typemux.Middleware[testEvent] {
+		return func(next typemux.HandlerFunc[testEvent]) typemux.HandlerFunc[testEvent] {
+			return func(ctx context.Context, e testEvent) error {
+				order = append(order, s)
+				return next(ctx, e)
+			}
+		}
+	}
+
+	src := typemux.NewDispatchRegistry()
+	h := typemux.RegisterDispatch(src, func(ctx context.Context, e testEvent) error {
+		order = append(order, "handler")
+		return nil
+	})
+	typemux.UseFor(src, record("src"))
+	typemux.RegisterFallback(src, func(ctx context.Context, v any) error { return nil })
+	typemux.RegisterQuery(src, func(ctx context.Context, q getUser) (userView, error) { return userView{}, nil })
+	typemux.RegisterBatchDispatch(src, func(ctx context.Context, es []testEvent) error { return nil })
+	typemux.Subscribe(src, func(ctx context.Context, e testEvent) error { return nil })
+
+	dst := typemux.NewDispatchRegistry()
+	typemux.RegisterFallback(dst, func(ctx context.Context, v any) error { return nil })
+	typemux.Subscribe(dst, func(ctx context.Context, e testEvent) error { return nil })
+	typemux.UseFor(dst, record("dst"))
+
+	rep, err := typemux.Merge(dst, typemux.MergeKeepFirst, src, dst)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []typemux.MergeEntry{{Kind: typemux.MergeFallback, Source: 0}}
+	if !reflect.DeepEqual(rep.Conflicts, expected) {
+		t.Errorf("expected fallback conflict, got %v", rep.Conflicts)
+	}
+	if len(rep.Merged) != 4 {
+		t.Errorf("expected handler, subscriber, query and batch merged, got %v", rep.Merged)
+	}
+
+	// The source's Registration doesn't reach into dst.
+	h.Unregister()
+
+	if err := typemux.Dispatch(dst, context.Background(), testEvent{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"dst", "src", "handler"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("expected %v, got %v", want, order)
+	}
+
+	var calls int
+	typemux.Subscribe(dst, func(ctx context.Context, e testEvent) error {
+		calls++
+		return nil
+	})
+	if err := typemux.Publish(dst, context.Background(), testEvent{}, typemux.PublishSequential); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected subscribers to accumulate, got %d calls", calls)
+	}
+
+	if _, err := typemux.Ask[getUser, userView](dst, context.Background(), getUser{ID: "u1"}); err != nil {
+		t.Errorf("expected merged query, got %v", err)
+	}
+	if err := typemux.DispatchBatch(dst, context.Background(), []any{testEvent{}}); err != nil {
+		t.Errorf("expected merged batch handler, got %v", err)
+	}
+}
+
+func TestMerge_CodecRegistry(t *testing.T) {
+	a := typemux.NewCodecRegistry()
+	typemux.RegisterCodec(a, "user_created", typemux.JSONCodec[UserCreated]())
+	b := typemux.NewCodecRegistry()
+	// Same key over another wire format is no conflict.
+	typemux.RegisterCodec(b, "user_created", typemux.NewCodec(
+		func(u UserCreated) (string, error) { return u.ID, nil },
+		func(s string) (UserCreated, error) { return UserCreated{ID: s}, nil },
+	))
+
+	dst := typemux.NewCodecRegistry(typemux.WithStrictDuplicates())
+	rep, err := typemux.Merge(dst, typemux.MergeError, a, b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rep.Merged) != 4 {
+		t.Errorf("expected 4 merged entries, got %v", rep.Merged)
+	}
+
+	v, err := typemux.CreateType(dst.Seal(), "user_created", "u1")
+	if err != nil || v.(UserCreated).ID != "u1" {
+		t.Errorf("expected string factory, got %v, %v", v, err)
+	}
+	if _, err := typemux.CreateType(dst, "user_created", []byte(`{}`)); err != nil {
+		t.Errorf("expected bytes factory, got %v", err)
+	}
+}
@@ -0,0 +1,511 @@
+package typemux
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultLatencyBuckets are the latency histogram bounds used when
+// MetricsConfig leaves them empty.
+var DefaultLatencyBuckets = []time.Duration{
+	100 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// DefaultSizeBuckets are the codec data size histogram bounds, in bytes, used
+// when MetricsConfig leaves them empty.
+var DefaultSizeBuckets = []int{64, 256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20}
+
+// MetricsConfig configures a Metrics collector.
+type MetricsConfig struct {
+	// Namespace prefixes the Prometheus metric names. Defaults to "typemux".
+	Namespace string
+	// LatencyBuckets are the upper bounds of the latency histogram buckets,
+	// ascending. Defaults to DefaultLatencyBuckets.
+	LatencyBuckets []time.Duration
+	// SizeBuckets are the upper bounds of the codec data size histogram
+	// buckets, in bytes, ascending. Defaults to DefaultSizeBuckets.
+	SizeBuckets []int
+	// Clock measures latencies. Defaults to the system clock.
+	Clock Clock
+}
+
+// Metrics collects dispatch counts, error counts and latencies per type, and
+// the same plus data sizes per codec. Use NewMetrics to create one, attach
+// Middleware, MetricsFor or CodecMiddleware, then export through Handler or
+// Expvar.
+//
+// Recording is lock-free: series are created once per type or codec, and
+// updated with atomic operations.
+type Metrics struct {
+	namespace      string
+	latencyBuckets []float64 // seconds
+	latencyNanos   []int64
+	sizeBuckets    []float64
+	sizeBytes      []int64
+	clock          Clock
+
+	dispatch sync.Map // reflect.Type -> *dispatchSeries
+	codecs   sync.Map // codec key -> *codecKeySeries
+
+	// mu serializes series creation for a codec key.
+	mu sync.Mutex
+}
+
+type dispatchSeries struct {
+	typ     string
+	count   atomic.Uint64
+	errors  atomic.Uint64
+	latency histogram
+}
+
+type codecKeySeries struct {
+	series atomic.Pointer[[]*codecSeries]
+}
+
+type codecSeries struct {
+	typ       reflect.Type
+	dataType  reflect.Type
+	direction CodecDirection
+
+	key      string
+	keyType  string
+	count    atomic.Uint64
+	errors   atomic.Uint64
+	latency  histogram
+	sizes    histogram
+	hasSizes atomic.Bool
+}
+
+// histogram counts observations into buckets by upper bound; the extra last
+// bucket counts those above every bound.
+type histogram struct {
+	counts []atomic.Uint64
+	sum    atomic.Int64
+}
+
+func newHistogram(bounds int) histogram {
+	return histogram{counts: make([]atomic.Uint64, bounds+1)}
+}
+
+func (h *histogram) observe(bounds []int64, v int64) {
+	i, _ := slices.BinarySearch(bounds, v)
+	h.counts[i].Add(1)
+	h.sum.Add(v)
+}
+
+// NewMetrics creates an empty Metrics collector.
+func NewMetrics(cfg MetricsConfig) *Metrics {
+	m := &Metrics{namespace: cmp.Or(cfg.Namespace, "typemux"), clock: clockOrSystem(cfg.Clock)}
+
+	latency := cfg.LatencyBuckets
+	if len(latency) == 0 {
+		latency = DefaultLatencyBuckets
+	}
+	for _, b := range latency {
+		m.latencyNanos = append(m.latencyNanos, int64(b))
+		m.latencyBuckets = append(m.latencyBuckets, b.Seconds())
+	}
+
+	sizes := cfg.SizeBuckets
+	if len(sizes) == 0 {
+		sizes = DefaultSizeBuckets
+	}
+	for _, b := range sizes {
+		m.sizeBytes = append(m.sizeBytes, int64(b))
+		m.sizeBuckets = append(m.sizeBuckets, float64(b))
+	}
+	return m
+}
+
+// Middleware returns a DispatchMiddleware recording each dispatch under the
+// value's concrete type.
+func (m *Metrics) Middleware() DispatchMiddleware {
+	return func(ctx context.Context, event any, next func(context.Context) error) error {
+		return m.do(reflect.TypeOf(event), ctx, next)
+	}
+}
+
+// MetricsFor returns a typed Middleware recording each call of the handler
+// for T in m.
+func MetricsFor[T any](m *Metrics) Middleware[T] {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	return func(next HandlerFunc[T]) HandlerFunc[T] {
+		return func(ctx context.Context, val T) error {
+			return m.do(typ, ctx, func(ctx context.Context) error {
+				return next(ctx, val)
+			})
+		}
+	}
+}
+
+// CodecMiddleware returns a CodecMiddleware recording each unmarshal and
+// marshal per codec key, type, data type and direction. Data sizes are
+// recorded for DATA types that are byte slices or strings. Attach it with
+// WithCodecMiddleware.
+func (m *Metrics) CodecMiddleware() CodecMiddleware {
+	return func(ctx context.Context, call CodecCall, in any, next func(context.Context, any) (any, error)) (any, error) {
+		s := m.codecSeries(call)
+
+		start := m.clock.Now()
+		out, err := next(ctx, in)
+		s.latency.observe(m.latencyNanos, int64(m.clock.Now().Sub(start)))
+		s.count.Add(1)
+		if err != nil {
+			s.errors.Add(1)
+			return out, err
+		}
+
+		data := out
+		if call.Direction == CodecUnmarshal {
+			data = in
+		}
+		if n, ok := dataSize(data); ok {
+			s.sizes.observe(m.sizeBytes, int64(n))
+			s.hasSizes.Store(true)
+		}
+		return out, err
+	}
+}
+
+func (m *Metrics) do(typ reflect.Type, ctx context.Context, call func(context.Context) error) error {
+	s, ok := m.dispatch.Load(typ)
+	if !ok {
+		s, _ = m.dispatch.LoadOrStore(typ, &dispatchSeries{typ: seriesTypeName(typ), latency: newHistogram(len(m.latencyNanos))})
+	}
+	series := s.(*dispatchSeries)
+
+	start := m.clock.Now()
+	err := call(ctx)
+	series.latency.observe(m.latencyNanos, int64(m.clock.Now().Sub(start)))
+	series.count.Add(1)
+	if err != nil {
+		series.errors.Add(1)
+	}
+	return err
+}
+
+// codecSeries returns the series for call, creating it on first use. Lookups
+// scan the few series of the codec key without locking.
+func (m *Metrics) codecSeries(call CodecCall) *codecSeries {
+	ks, ok := m.codecs.Load(call.Key)
+	if !ok {
+		ks, _ = m.codecs.LoadOrStore(call.Key, &codecKeySeries{})
+	}
+	keySeries := ks.(*codecKeySeries)
+
+	if s := findCodecSeries(keySeries.series.Load(), call); s != nil {
+		return s
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	list := keySeries.series.Load()
+	if s := findCodecSeries(list, call); s != nil {
+		return s
+	}
+	s := &codecSeries{
+		typ:       call.Type,
+		dataType:  call.DataType,
+		direction: call.Direction,
+		key:       fmt.Sprint(call.Key),
+		keyType:   seriesTypeName(reflect.TypeOf(call.Key)),
+		latency:   newHistogram(len(m.latencyNanos)),
+		sizes:     newHistogram(len(m.sizeBytes)),
+	}
+	var next []*codecSeries
+	if list != nil {
+		next = slices.Clone(*list)
+	}
+	next = append(next, s)
+	keySeries.series.Store(&next)
+	return s
+}
+
+func findCodecSeries(list *[]*codecSeries, call CodecCall) *codecSeries {
+	if list == nil {
+		return nil
+	}
+	for _, s := range *list {
+		if s.typ == call.Type && s.dataType == call.DataType && s.direction == call.Direction {
+			return s
+		}
+	}
+	return nil
+}
+
+// seriesTypeName returns typ's name for series labels, with every named type
+// in it qualified by its full package path so that types from packages
+// sharing a name get distinct series; "<nil>" for a nil type.
+func seriesTypeName(typ reflect.Type) string {
+	if typ == nil {
+		return "<nil>"
+	}
+	if typ.Name() != "" {
+		if typ.PkgPath() == "" {
+			return typ.Name()
+		}
+		return typ.PkgPath() + "." + typ.Name()
+	}
+
+	switch typ.Kind() {
+	case reflect.Pointer:
+		return "*" + seriesTypeName(typ.Elem())
+	case reflect.Slice:
+		return "[]" + seriesTypeName(typ.Elem())
+	case reflect.Array:
+		return "[" + strconv.Itoa(typ.Len()) + "]" + seriesTypeName(typ.Elem())
+	case reflect.Map:
+		return "map[" + seriesTypeName(typ.Key()) + "]" + seriesTypeName(typ.Elem())
+	default:
+		return typ.String()
+	}
+}
+
+// dataSize returns the length of wire data that is a byte slice or a string.
+func dataSize(data any) (int, bool) {
+	switch d := data.(type) {
+	case []byte:
+		return len(d), true
+	case string:
+		return len(d), true
+	}
+
+	v := reflect.ValueOf(data)
+	switch {
+	case v.Kind() == reflect.String:
+		return v.Len(), true
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		return v.Len(), true
+	}
+	return 0, false
+}
+
+// MetricsSnapshot is a point-in-time copy of the collected metrics, sorted by
+// type and codec key.
+type MetricsSnapshot struct {
+	Dispatch []DispatchMetrics `json:"dispatch"`
+	Codecs   []CodecMetrics    `json:"codecs"`
+}
+
+// DispatchMetrics are the metrics of one dispatched type.
+type DispatchMetrics struct {
+	// Type is the type's name qualified by its package path, e.g.
+	// "example.com/app/events.OrderPlaced".
+	Type    string    `json:"type"`
+	Count   uint64    `json:"count"`
+	Errors  uint64    `json:"errors"`
+	Latency Histogram `json:"latency_seconds"`
+}
+
+// CodecMetrics are the metrics of one codec half. Types are named as in
+// DispatchMetrics.
+type CodecMetrics struct {
+	// Key is the codec key as formatted by fmt.Sprint; KeyType tells apart
+	// keys formatting alike, such as 1 and "1".
+	Key       string    `json:"key"`
+	KeyType   string    `json:"key_type"`
+	Type      string    `json:"type"`
+	DataType  string    `json:"data_type"`
+	Direction string    `json:"direction"`
+	Count     uint64    `json:"count"`
+	Errors    uint64    `json:"errors"`
+	Latency   Histogram `json:"latency_seconds"`
+	// Sizes is nil unless the data type's size can be measured.
+	Sizes *Histogram `json:"size_bytes,omitempty"`
+}
+
+// Histogram is a snapshot of a histogram. Counts[i] counts the observations
+// up to Bounds[i] and above any lower bound; the last count is for those
+// above every bound.
+type Histogram struct {
+	Bounds []float64 `json:"bounds"`
+	Counts []uint64  `json:"counts"`
+	Sum    float64   `json:"sum"`
+}
+
+// Snapshot returns a copy of the metrics collected so far.
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	var snap MetricsSnapshot
+
+	m.dispatch.Range(func(_, v any) bool {
+		s := v.(*dispatchSeries)
+		snap.Dispatch = append(snap.Dispatch, DispatchMetrics{
+			Type:    s.typ,
+			Count:   s.count.Load(),
+			Errors:  s.errors.Load(),
+			Latency: s.latency.snapshot(m.latencyBuckets, time.Nanosecond.Seconds()),
+		})
+		return true
+	})
+	m.codecs.Range(func(_, v any) bool {
+		list := v.(*codecKeySeries).series.Load()
+		if list == nil {
+			return true
+		}
+		for _, s := range *list {
+			cm := CodecMetrics{
+				Key:       s.key,
+				KeyType:   s.keyType,
+				Type:      seriesTypeName(s.typ),
+				DataType:  seriesTypeName(s.dataType),
+				Direction: s.direction.String(),
+				Count:     s.count.Load(),
+				Errors:    s.errors.Load(),
+				Latency:   s.latency.snapshot(m.latencyBuckets, time.Nanosecond.Seconds()),
+			}
+			if s.hasSizes.Load() {
+				sizes := s.sizes.snapshot(m.sizeBuckets, 1)
+				cm.Sizes = &sizes
+			}
+			snap.Codecs = append(snap.Codecs, cm)
+		}
+		return true
+	})
+
+	slices.SortFunc(snap.Dispatch, func(a, b DispatchMetrics) int {
+		return cmp.Compare(a.Type, b.Type)
+	})
+	slices.SortFunc(snap.Codecs, func(a, b CodecMetrics) int {
+		return cmp.Or(
+			cmp.Compare(a.Key, b.Key),
+			cmp.Compare(a.KeyType, b.KeyType),
+			cmp.Compare(a.Type, b.Type),
+			cmp.Compare(a.DataType, b.DataType),
+			cmp.Compare(a.Direction, b.Direction),
+		)
+	})
+	return snap
+}
+
+func (h *histogram) snapshot(bounds []float64, unit float64) Histogram {
+	counts := make([]uint64, len(h.counts))
+	for i := range h.counts {
+		counts[i] = h.counts[i].Load()
+	}
+	return Histogram{Bounds: bounds, Counts: counts, Sum: float64(h.sum.Load()) * unit}
+}
+
+// Expvar returns an expvar.Var rendering the current Snapshot as JSON.
+// Publish it with expvar.Publish under a name of your choice.
+func (m *Metrics) Expvar() expvar.Var {
+	return expvar.Func(func() any { return m.Snapshot() })
+}
+
+// Handler returns an http.Handler serving the metrics in the Prometheus text
+// exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = m.WritePrometheus(w)
+	})
+}
+
+// WritePrometheus writes the metrics to w in the Prometheus text exposition
+// format.
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	snap := m.Snapshot()
+	bw := bufio.NewWriter(w)
+	ns := m.namespace
+
+	writeHeader(bw, ns+"_dispatch_total", "counter", "Dispatches per type.")
+	for _, d := range snap.Dispatch {
+		writeSample(bw, ns+"_dispatch_total", labels("type", d.Type), float64(d.Count))
+	}
+	writeHeader(bw, ns+"_dispatch_errors_total", "counter", "Failed dispatches per type.")
+	for _, d := range snap.Dispatch {
+		writeSample(bw, ns+"_dispatch_errors_total", labels("type", d.Type), float64(d.Errors))
+	}
+	writeHeader(bw, ns+"_dispatch_duration_seconds", "histogram", "Dispatch latency per type.")
+	for _, d := range snap.Dispatch {
+		writeHistogram(bw, ns+"_dispatch_duration_seconds", labels("type", d.Type), d.Latency)
+	}
+
+	codecLabels := func(c CodecMetrics) string {
+		return labels("key", c.Key, "key_type", c.KeyType, "type", c.Type, "data_type", c.DataType, "direction", c.Direction)
+	}
+	writeHeader(bw, ns+"_codec_total", "counter", "Codec calls per key, type, data type and direction.")
+	for _, c := range snap.Codecs {
+		writeSample(bw, ns+"_codec_total", codecLabels(c), float64(c.Count))
+	}
+	writeHeader(bw, ns+"_codec_errors_total", "counter", "Failed codec calls per key, type, data type and direction.")
+	for _, c := range snap.Codecs {
+		writeSample(bw, ns+"_codec_errors_total", codecLabels(c), float64(c.Errors))
+	}
+	writeHeader(bw, ns+"_codec_duration_seconds", "histogram", "Codec call latency.")
+	for _, c := range snap.Codecs {
+		writeHistogram(bw, ns+"_codec_duration_seconds", codecLabels(c), c.Latency)
+	}
+	writeHeader(bw, ns+"_codec_size_bytes", "histogram", "Size of the data marshaled or unmarshaled.")
+	for _, c := range snap.Codecs {
+		if c.Sizes != nil {
+			writeHistogram(bw, ns+"_codec_size_bytes", codecLabels(c), *c.Sizes)
+		}
+	}
+
+	return bw.Flush()
+}
+
+func writeHeader(w *bufio.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func writeSample(w *bufio.Writer, name, labels string, v float64) {
+	fmt.Fprintf(w, "%s{%s} %s\n", name, labels, formatFloat(v))
+}
+
+func writeHistogram(w *bufio.Writer, name, labels string, h Histogram) {
+	var cumulative uint64
+	for i, c := range h.Counts {
+		cumulative += c
+		le := "+Inf"
+		if i < len(h.Bounds) {
+			le = formatFloat(h.Bounds[i])
+		}
+		writeSample(w, name+"_bucket", labels+`,le="`+le+`"`, float64(cumulative))
+	}
+	writeSample(w, name+"_sum", labels, h.Sum)
+	writeSample(w, name+"_count", labels, float64(cumulative))
+}
+
+// labels formats name-value pairs as Prometheus labels.
+func labels(pairs ...string) string {
+	var b strings.Builder
+	for i := 0; i < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i])
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(pairs[i+1]))
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,224 @@
+package typemux_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	mathrand "math/rand"
+	"math/rand/v2"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/struct0x/typemux"
+)
+
+func TestMetrics_Dispatch(t *testing.T) {
+	clock := newFakeClock()
+	metrics := typemux.NewMetrics(typemux.MetricsConfig{Clock: clock})
+
+	reg := typemux.NewRegistry()
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error {
+		clock.Advance(2 * time.Millisecond)
+		if e.Name == "bad" {
+			return errDeadlock
+		}
+		return nil
+	})
+	typemux.RegisterDispatch(reg, func(ctx context.Context, o OrderPlaced) error { return nil }, typemux.MetricsFor[OrderPlaced](metrics))
+
+	sealed := reg.Seal()
+	_ = typemux.Dispatch(reg, context.Background(), testEvent{}, metrics.Middleware())
+	_ = typemux.Dispatch(sealed, context.Background(), testEvent{Name: "bad"}, metrics.Middleware())
+	_ = typemux.Dispatch(sealed, context.Background(), OrderPlaced{})
+
+	snap := metrics.Snapshot()
+	if len(snap.Dispatch) != 2 {
+		t.Fatalf("expected 2 dispatch series, got %+v", snap.Dispatch)
+	}
+
+	order, event := snap.Dispatch[0], snap.Dispatch[1]
+	if order.Type != "github.com/struct0x/typemux_test.OrderPlaced" || order.Count != 1 || order.Errors != 0 {
+		t.Errorf("unexpected series: %+v", order)
+	}
+	if event.Type != "github.com/struct0x/typemux_test.testEvent" || event.Count != 2 || event.Errors != 1 {
+		t.Errorf("unexpected series: %+v", event)
+	}
+
+	// 2ms falls into the 5ms bucket.
+	want := make([]uint64, len(typemux.DefaultLatencyBuckets)+1)
+	want[3] = 2
+	if !slices.Equal(event.Latency.Counts, want) || event.Latency.Sum != 0.004 {
+		t.Errorf("unexpected latency histogram: %+v", event.Latency)
+	}
+}
+
+func TestMetrics_Codec(t *testing.T) {
+	metrics := typemux.NewMetrics(typemux.MetricsConfig{SizeBuckets: []int{16, 64}})
+
+	reg := typemux.NewRegistry(typemux.WithCodecMiddleware(metrics.CodecMiddleware()))
+	typemux.RegisterCodec(reg, "order_placed", typemux.JSONCodec[OrderPlaced]())
+
+	_, data, err := typemux.Serialize[string, []byte](reg, OrderPlaced{OrderID: "o1", Amount: 5})
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if _, err := typemux.CreateType(reg, "order_placed", data); err != nil {
+		t.Fatalf("CreateType failed: %v", err)
+	}
+	if _, err := typemux.CreateType(reg, "order_placed", []byte("{")); err == nil {
+		t.Fatal("expected an unmarshal error")
+	}
+
+	snap := metrics.Snapshot()
+	if len(snap.Codecs) != 2 {
+		t.Fatalf("expected 2 codec series, got %+v", snap.Codecs)
+	}
+
+	marshal, unmarshal := snap.Codecs[0], snap.Codecs[1]
+	if marshal.Key != "order_placed" || marshal.Type != "github.com/struct0x/typemux_test.OrderPlaced" || marshal.DataType != "[]uint8" || marshal.Direction != "marshal" {
+		t.Errorf("unexpected series: %+v", marshal)
+	}
+	if marshal.Count != 1 || unmarshal.Count != 2 || unmarshal.Errors != 1 {
+		t.Errorf("unexpected counts: %+v, %+v", marshal, unmarshal)
+	}
+
+	// Failed calls aren't sized; the 28-byte payload falls into the 64-byte bucket.
+	for _, c := range []typemux.CodecMetrics{marshal, unmarshal} {
+		if c.Sizes == nil || !slices.Equal(c.Sizes.Counts, []uint64{0, 1, 0}) || c.Sizes.Sum != float64(len(data)) {
+			t.Errorf("%s: unexpected size histogram: %+v", c.Direction, c.Sizes)
+		}
+	}
+}
+
+func TestMetrics_Export(t *testing.T) {
+	metrics := typemux.NewMetrics(typemux.MetricsConfig{
+		Namespace:      "app",
+		LatencyBuckets: []time.Duration{time.Millisecond},
+		Clock:          newFakeClock(),
+	})
+
+	reg := typemux.NewRegistry(typemux.WithCodecMiddleware(metrics.CodecMiddleware()))
+	typemux.RegisterCodec(reg, `quoted"key`, typemux.JSONCodec[OrderPlaced]())
+	typemux.RegisterDispatch(reg, func(ctx context.Context, o OrderPlaced) error { return errDeadlock })
+
+	_ = typemux.Dispatch(reg, context.Background(), OrderPlaced{}, metrics.Middleware())
+	if _, _, err := typemux.Serialize[string, []byte](reg, OrderPlaced{}); err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+
+	body, _ := io.ReadAll(rec.Body)
+	for _, line := range []string{
+		"# TYPE app_dispatch_total counter",
+		`app_dispatch_total{type="github.com/struct0x/typemux_test.OrderPlaced"} 1`,
+		`app_dispatch_errors_total{type="github.com/struct0x/typemux_test.OrderPlaced"} 1`,
+		"# TYPE app_dispatch_duration_seconds histogram",
+		`app_dispatch_duration_seconds_bucket{type="github.com/struct0x/typemux_test.OrderPlaced",le="0.001"} 1`,
+		`app_dispatch_duration_seconds_bucket{type="github.com/struct0x/typemux_test.OrderPlaced",le="+Inf"} 1`,
+		`app_dispatch_duration_seconds_sum{type="github.com/struct0x/typemux_test.OrderPlaced"} 0`,
+		`app_dispatch_duration_seconds_count{type="github.com/struct0x/typemux_test.OrderPlaced"} 1`,
+		`app_codec_total{key="quoted\"key",key_type="string",type="github.com/struct0x/typemux_test.OrderPlaced",data_type="[]uint8",direction="marshal"} 1`,
+		`app_codec_size_bytes_count{key="quoted\"key",key_type="string",type="github.com/struct0x/typemux_test.OrderPlaced",data_type="[]uint8",direction="marshal"} 1`,
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Errorf("expected line %q in:\n%s", line, body)
+		}
+	}
+
+	var snap typemux.MetricsSnapshot
+	if err := json.Unmarshal([]byte(metrics.Expvar().String()), &snap); err != nil {
+		t.Fatalf("invalid expvar JSON: %v", err)
+	}
+	if len(snap.Dispatch) != 1 || snap.Dispatch[0].Errors != 1 || len(snap.Codecs) != 1 || snap.Codecs[0].Key != `quoted"key` {
+		t.Errorf("unexpected expvar snapshot: %+v", snap)
+	}
+}
+
+func TestMetrics_DistinctLabels(t *testing.T) {
+	metrics := typemux.NewMetrics(typemux.MetricsConfig{})
+	next := func(context.Context) error { return nil }
+
+	// Both types are named rand.Rand.
+	_ = metrics.Middleware()(context.Background(), &mathrand.Rand{}, next)
+	_ = metrics.Middleware()(context.Background(), &rand.Rand{}, next)
+
+	reg := typemux.NewRegistry(typemux.WithCodecMiddleware(metrics.CodecMiddleware()))
+	typemux.RegisterCodec(reg, 1, typemux.JSONCodec[OrderPlaced]())
+	typemux.RegisterCodec(reg, "1", typemux.JSONCodec[UserCreated]())
+	_, _ = typemux.CreateType(reg, 1, []byte("{}"))
+	_, _ = typemux.CreateType(reg, "1", []byte("{}"))
+
+	snap := metrics.Snapshot()
+	var types []string
+	for _, d := range snap.Dispatch {
+		types = append(types, d.Type)
+	}
+	if want := []string{"*math/rand.Rand", "*math/rand/v2.Rand"}; !slices.Equal(types, want) {
+		t.Errorf("expected types %v, got %v", want, types)
+	}
+	var keys []string
+	for _, c := range snap.Codecs {
+		keys = append(keys, c.KeyType+":"+c.Key)
+	}
+	if want := []string{"int:1", "string:1"}; !slices.Equal(keys, want) {
+		t.Errorf("expected keys %v, got %v", want, keys)
+	}
+}
+
+func TestMetrics_SealedZeroAlloc(t *testing.T) {
+	metrics := typemux.NewMetrics(typemux.MetricsConfig{})
+
+	reg := typemux.NewRegistry()
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error { return nil }, typemux.MetricsFor[testEvent](metrics))
+	sealed := reg.Seal()
+
+	ctx := context.Background()
+	var ev any = testEvent{Name: "e1"}
+	_ = typemux.Dispatch(sealed, ctx, ev)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = typemux.Dispatch(sealed, ctx, ev)
+	})
+	if allocs != 0 {
+		t.Errorf("expected zero allocations, got %v", allocs)
+	}
+	if snap := metrics.Snapshot(); snap.Dispatch[0].Count != 102 {
+		t.Errorf("expected 102 dispatches, got %d", snap.Dispatch[0].Count)
+	}
+}
+
+func TestMetrics_Concurrent(t *testing.T) {
+	metrics := typemux.NewMetrics(typemux.MetricsConfig{})
+	reg := typemux.NewRegistry()
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error { return nil })
+	sealed := reg.Seal()
+
+	done := make(chan error)
+	for range 8 {
+		go func() {
+			var err error
+			for range 100 {
+				err = errors.Join(err, typemux.Dispatch(sealed, context.Background(), testEvent{}, metrics.Middleware()))
+			}
+			done <- err
+		}()
+	}
+	for range 8 {
+		if err := <-done; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if snap := metrics.Snapshot(); snap.Dispatch[0].Count != 800 {
+		t.Errorf("expected 800 dispatches, got %d", snap.Dispatch[0].Count)
+	}
+}
@@ -0,0 +1,112 @@
+package typemux
+
+import (
+	"errors"
+	"log/slog"
+	"reflect"
+)
+
+// ErrDuplicateRegistration is the error registration panics with in strict
+// mode (see WithStrictDuplicates) when the slot is already taken.
+var ErrDuplicateRegistration = errors.New("duplicate registration")
+
+// Option configures a registry at construction. Options are accepted by
+// NewRegistry, NewDispatchRegistry, NewCodecRegistry and NewAggregate.
+type Option func(*options)
+
+type options struct {
+	strict        bool
+	pointers      PointerPolicy
+	ignoreUnknown bool
+	limits        map[reflect.Type]*Limiter
+	recover       *RecoverConfig
+	codecMW       []CodecMiddleware
+	regLog        *slog.Logger
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithStrictDuplicates makes registration panic with an error wrapping
+// ErrDuplicateRegistration instead of replacing an existing registration.
+// It covers handlers by type (RegisterDispatch, RegisterBatchDispatch,
+// RegisterQuery, RegisterFallback) and codecs by (key, DATA) and (T, DATA).
+// Subscribers accumulate by design and are never duplicates.
+//
+// A slot freed with Registration.Unregister may be registered again.
+func WithStrictDuplicates() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// PointerPolicy controls how lookups fall back between a type and the
+// pointer to it when there is no exact match.
+type PointerPolicy int
+
+const (
+	// PointerToValue lets a *T fall back to the handler for T, receiving the
+	// dereferenced value. This is the default.
+	PointerToValue PointerPolicy = iota
+	// PointerExact disables all pointer fallbacks: only exact types match.
+	PointerExact
+	// ValueToPointer lets a T fall back to the handler for *T, receiving a
+	// pointer to a copy of the value. *T does not fall back to T.
+	ValueToPointer
+	// PointerBidirectional combines PointerToValue and ValueToPointer.
+	PointerBidirectional
+)
+
+func (p PointerPolicy) derefs() bool {
+	return p == PointerToValue || p == PointerBidirectional
+}
+
+func (p PointerPolicy) addresses() bool {
+	return p == ValueToPointer || p == PointerBidirectional
+}
+
+// WithPointerPolicy sets how the registry falls back between pointer and
+// value types. It applies to Dispatch, Publish, DispatchBatch and Serialize.
+func WithPointerPolicy(p PointerPolicy) Option {
+	return func(o *options) {
+		o.pointers = p
+	}
+}
+
+// lookupPointer resolves typ through get, applying the policy's pointer
+// fallbacks. It returns the argument to pass on, which is v converted to the
+// matched type in the fallback cases.
+func lookupPointer[H any](typ reflect.Type, v any, policy PointerPolicy, get func(reflect.Type) (H, bool)) (H, any, bool) {
+	if h, ok := get(typ); ok {
+		return h, v, true
+	}
+
+	var zero H
+	if typ == nil {
+		return zero, nil, false
+	}
+
+	if typ.Kind() == reflect.Ptr {
+		if policy.derefs() {
+			if h, ok := get(typ.Elem()); ok {
+				return h, reflect.ValueOf(v).Elem().Interface(), true
+			}
+		}
+		return zero, nil, false
+	}
+
+	if policy.addresses() {
+		if h, ok := get(reflect.PointerTo(typ)); ok {
+			ptr := reflect.New(typ)
+			ptr.Elem().Set(reflect.ValueOf(v))
+			return h, ptr.Interface(), true
+		}
+	}
+
+	return zero, nil, false
+}
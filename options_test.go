@@ -0,0 +1,195 @@
+package typemux_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/struct0x/typemux"
+)
+
+// expectDuplicatePanic runs register and asserts it panics with
+// ErrDuplicateRegistration and a message naming what was duplicated.
+func expectDuplicatePanic(t *testing.T, mention string, register func()) {
+	t.Helper()
+
+	defer func() {
+		t.Helper()
+
+		r := recover()
+		err, ok := r.(error)
+		if !ok {
+			t.Fatalf("expected panic with error, got %v", r)
+		}
+		if !errors.Is(err, typemux.ErrDuplicateRegistration) {
+			t.Errorf("expected ErrDuplicateRegistration, got %v", err)
+		}
+		if !strings.Contains(err.Error(), mention) {
+			t.Errorf("expected message to mention %q, got %q", mention, err)
+		}
+	}()
+
+	register()
+}
+
+func TestStrictDuplicates_Dispatch(t *testing.T) {
+	reg := typemux.NewDispatchRegistry(typemux.WithStrictDuplicates())
+
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error { return nil })
+
+	expectDuplicatePanic(t, "typemux_test.testEvent", func() {
+		typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error { return nil })
+	})
+}
+
+func TestStrictDuplicates_AfterUnregister(t *testing.T) {
+	reg := typemux.NewRegistry(typemux.WithStrictDuplicates())
+
+	h := typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error { return nil })
+	h.Unregister()
+
+	// The slot is free again.
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error { return nil })
+}
+
+func TestStrictDuplicates_Codec(t *testing.T) {
+	t.Run("same_key_and_data", func(t *testing.T) {
+		reg := typemux.NewCodecRegistry(typemux.WithStrictDuplicates())
+		typemux.RegisterCodec(reg, "user", typemux.JSONCodec[UserCreated]())
+
+		expectDuplicatePanic(t, "key user", func() {
+			typemux.RegisterCodec(reg, "user", typemux.JSONCodec[OrderPlaced]())
+		})
+
+		// The rejected codec left nothing behind.
+		if _, _, err := typemux.Serialize[string, []byte](reg, OrderPlaced{}); !errors.Is(err, typemux.ErrSerializerNotFound) {
+			t.Errorf("expected ErrSerializerNotFound, got %v", err)
+		}
+	})
+
+	t.Run("same_type_and_data", func(t *testing.T) {
+		reg := typemux.NewRegistry(typemux.WithStrictDuplicates())
+		typemux.RegisterCodec(reg, "user", typemux.JSONCodec[UserCreated]())
+
+		expectDuplicatePanic(t, "type typemux_test.UserCreated", func() {
+			typemux.RegisterCodec(reg, "user_v2", typemux.JSONCodec[UserCreated]())
+		})
+	})
+
+	t.Run("other_data_type_is_no_duplicate", func(t *testing.T) {
+		reg := typemux.NewRegistry(typemux.WithStrictDuplicates())
+		typemux.RegisterCodec(reg, "user", typemux.JSONCodec[UserCreated]())
+		typemux.RegisterCodec(reg, "user", typemux.NewCodec(
+			func(u UserCreated) (string, error) { return u.ID, nil },
+			func(s string) (UserCreated, error) { return UserCreated{ID: s}, nil },
+		))
+	})
+}
+
+func TestStrictDuplicates_QueryBatchFallback(t *testing.T) {
+	reg := typemux.NewRegistry(typemux.WithStrictDuplicates())
+
+	typemux.RegisterQuery(reg, func(ctx context.Context, q getUser) (userView, error) { return userView{}, nil })
+	expectDuplicatePanic(t, "query handler", func() {
+		typemux.RegisterQuery(reg, func(ctx context.Context, q getUser) (string, error) { return "", nil })
+	})
+
+	typemux.RegisterBatchDispatch(reg, func(ctx context.Context, b []testEvent) error { return nil })
+	expectDuplicatePanic(t, "batch handler", func() {
+		typemux.RegisterBatchDispatch(reg, func(ctx context.Context, b []testEvent) error { return nil })
+	})
+
+	typemux.RegisterFallback(reg, func(ctx context.Context, v any) error { return nil })
+	expectDuplicatePanic(t, "fallback", func() {
+		typemux.RegisterFallback(reg, func(ctx context.Context, v any) error { return nil })
+	})
+
+	// Subscribers accumulate and are never duplicates.
+	typemux.Subscribe(reg, func(ctx context.Context, e testEvent) error { return nil })
+	typemux.Subscribe(reg, func(ctx context.Context, e testEvent) error { return nil })
+}
+
+func TestPointerPolicy_Exact(t *testing.T) {
+	reg := typemux.NewRegistry(typemux.WithPointerPolicy(typemux.PointerExact))
+
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error { return nil })
+	typemux.RegisterCodec(reg, "user", typemux.JSONCodec[UserCreated]())
+
+	if err := typemux.Dispatch(reg, context.Background(), &testEvent{}); !errors.Is(err, typemux.ErrHandlerNotFound) {
+		t.Errorf("expected ErrHandlerNotFound, got %v", err)
+	}
+	if err := typemux.Dispatch(reg.Seal(), context.Background(), &testEvent{}); !errors.Is(err, typemux.ErrHandlerNotFound) {
+		t.Errorf("expected ErrHandlerNotFound from sealed registry, got %v", err)
+	}
+	if _, _, err := typemux.Serialize[string, []byte](reg, &UserCreated{}); !errors.Is(err, typemux.ErrSerializerNotFound) {
+		t.Errorf("expected ErrSerializerNotFound, got %v", err)
+	}
+}
+
+func TestPointerPolicy_ValueToPointer(t *testing.T) {
+	reg := typemux.NewRegistry(typemux.WithPointerPolicy(typemux.ValueToPointer))
+
+	var received *testEvent
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e *testEvent) error {
+		received = e
+		return nil
+	})
+
+	sent := testEvent{Name: "value"}
+
+	t.Run("standard_registry", func(t *testing.T) {
+		received = nil
+		if err := typemux.Dispatch(reg, context.Background(), sent); err != nil {
+			t.Fatalf("expected value dispatch to reach pointer handler, got: %v", err)
+		}
+		if received == nil || received.Name != "value" {
+			t.Errorf("expected pointer to a copy of the value, got %v", received)
+		}
+	})
+
+	t.Run("sealed_registry", func(t *testing.T) {
+		received = nil
+		if err := typemux.Dispatch(reg.Seal(), context.Background(), sent); err != nil {
+			t.Fatalf("expected value dispatch to reach pointer handler, got: %v", err)
+		}
+		if received == nil || received.Name != "value" {
+			t.Errorf("expected pointer to a copy of the value, got %v", received)
+		}
+	})
+
+	t.Run("pointer_to_value_disabled", func(t *testing.T) {
+		reg := typemux.NewRegistry(typemux.WithPointerPolicy(typemux.ValueToPointer))
+		typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error { return nil })
+
+		if err := typemux.Dispatch(reg, context.Background(), &testEvent{}); !errors.Is(err, typemux.ErrHandlerNotFound) {
+			t.Errorf("expected ErrHandlerNotFound, got %v", err)
+		}
+	})
+}
+
+func TestPointerPolicy_Bidirectional(t *testing.T) {
+	reg := typemux.NewRegistry(typemux.WithPointerPolicy(typemux.PointerBidirectional))
+
+	var out []string
+	typemux.Subscribe(reg, func(ctx context.Context, e *testEvent) error {
+		out = append(out, "pointer:"+e.Name)
+		return nil
+	})
+	typemux.Subscribe(reg, func(ctx context.Context, o OrderPlaced) error {
+		out = append(out, "value:"+o.OrderID)
+		return nil
+	})
+
+	sealed := reg.Seal()
+	if err := typemux.Publish(sealed, context.Background(), testEvent{Name: "a"}, typemux.PublishSequential); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := typemux.Publish(sealed, context.Background(), &OrderPlaced{OrderID: "b"}, typemux.PublishSequential); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if strings.Join(out, ",") != "pointer:a,value:b" {
+		t.Errorf("unexpected deliveries: %v", out)
+	}
+}
@@ -0,0 +1,166 @@
+package typemux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"runtime"
+	"sync"
+)
+
+// ErrNoPartitionKey is returned by PartitionedDispatcher.Submit when the
+// value's type has no registered key extractor and does not implement
+// Partitioned.
+var ErrNoPartitionKey = errors.New("no partition key")
+
+// Partitioned is implemented by values that carry their own partition key,
+// typically the ID of the aggregate they belong to.
+type Partitioned interface {
+	PartitionKey() string
+}
+
+// PartitionConfig configures a PartitionedDispatcher.
+type PartitionConfig struct {
+	// Lanes is the number of serial lanes running in parallel.
+	// Defaults to runtime.GOMAXPROCS(0).
+	Lanes int
+	// LaneSize is the number of values that may wait in each lane.
+	LaneSize int
+	// Middleware is applied to every Dispatch, outermost-first.
+	Middleware []DispatchMiddleware
+}
+
+type partitionKeyFunc func(v any) string
+
+type partitionDispatcher interface {
+	dispatcher
+	pointerPolicy() PointerPolicy
+}
+
+// PartitionedDispatcher dispatches values asynchronously while preserving
+// order per partition key: values with the same key are hashed onto the same
+// serial lane, and lanes run in parallel.
+//
+// Use NewPartitionedDispatcher() to create one, RegisterPartitionKey() to
+// teach it the keys of types that don't implement Partitioned, and
+// Shutdown() to stop it.
+type PartitionedDispatcher struct {
+	pool     workerPool
+	lanes    []chan asyncJob
+	pointers PointerPolicy
+
+	keysMu sync.RWMutex
+	keys   map[reflect.Type]partitionKeyFunc
+}
+
+// NewPartitionedDispatcher starts the lanes of a new PartitionedDispatcher over disp.
+func NewPartitionedDispatcher(disp partitionDispatcher, cfg PartitionConfig) *PartitionedDispatcher {
+	n := cfg.Lanes
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	laneSize := max(cfg.LaneSize, 0)
+
+	p := &PartitionedDispatcher{
+		pool:     newWorkerPool(disp, cfg.Middleware),
+		lanes:    make([]chan asyncJob, n),
+		pointers: disp.pointerPolicy(),
+		keys:     make(map[reflect.Type]partitionKeyFunc),
+	}
+
+	for i := range p.lanes {
+		p.lanes[i] = make(chan asyncJob, laneSize)
+		p.pool.start(p.lanes[i])
+	}
+
+	return p
+}
+
+// RegisterPartitionKey registers the key extractor for values of type T.
+// A registered extractor takes precedence over the Partitioned interface.
+// Pointers and values fall back to it under the pointer policy of the
+// dispatcher's registry, as in Dispatch; a nil pointer never does.
+func RegisterPartitionKey[T any](p *PartitionedDispatcher, key func(T) string) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+
+	p.keysMu.Lock()
+	defer p.keysMu.Unlock()
+
+	p.keys[typ] = func(v any) string {
+		return key(v.(T))
+	}
+}
+
+func (p *PartitionedDispatcher) partitionKey(v any) (string, error) {
+	typ := reflect.TypeOf(v)
+
+	// A nil pointer has no value to hand to an extractor for the type it
+	// points to.
+	policy := p.pointers
+	if typ != nil && typ.Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil() {
+		policy = PointerExact
+	}
+
+	p.keysMu.RLock()
+	key, arg, ok := lookupPointer(typ, v, policy, func(t reflect.Type) (partitionKeyFunc, bool) {
+		key, ok := p.keys[t]
+		return key, ok
+	})
+	p.keysMu.RUnlock()
+
+	if ok {
+		return key(arg), nil
+	}
+	if pv, ok := v.(Partitioned); ok {
+		return pv.PartitionKey(), nil
+	}
+	return "", fmt.Errorf("typemux: %w for type %v", ErrNoPartitionKey, typ)
+}
+
+// Submit queues v on the lane of its partition key and returns a Future for
+// its result. Values with the same key are dispatched in submission order.
+//
+// Submit blocks while the lane is full; ctx bounds only that wait. As with
+// AsyncDispatcher, handlers run with ctx's values but not its cancellation.
+//
+// Returns:
+//   - ErrNoPartitionKey if no key can be derived for v
+//   - ErrDispatcherClosed if Shutdown has been called, including while waiting
+//     for room
+//   - the context's error if ctx is done while waiting for room
+func (p *PartitionedDispatcher) Submit(ctx context.Context, v any) (*Future, error) {
+	key, err := p.partitionKey(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.pool.enter() {
+		return nil, ErrDispatcherClosed
+	}
+	defer p.pool.leave()
+
+	job := asyncJob{ctx: context.WithoutCancel(ctx), v: v, future: newFuture()}
+	if err := p.pool.send(ctx, p.lanes[laneIndex(key, len(p.lanes))], job); err != nil {
+		return nil, err
+	}
+	return job.future, nil
+}
+
+// Shutdown stops accepting new values and waits for every lane to drain. If
+// ctx is done first, Shutdown returns the context's error; the lanes still
+// finish draining in the background.
+func (p *PartitionedDispatcher) Shutdown(ctx context.Context) error {
+	return p.pool.shutdown(ctx, func() {
+		for _, lane := range p.lanes {
+			close(lane)
+		}
+	})
+}
+
+func laneIndex(key string, lanes int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(lanes))
+}
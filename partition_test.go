@@ -0,0 +1,191 @@
+package typemux_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/struct0x/typemux"
+)
+
+type orderEvent struct {
+	OrderID string
+	Seq     int
+}
+
+func (e orderEvent) PartitionKey() string { return e.OrderID }
+
+type customerEvent struct {
+	CustomerID string
+	Seq        int
+}
+
+func TestPartitionedDispatcher_PreservesOrderPerKey(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	var mu sync.Mutex
+	seen := map[string][]int{}
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e orderEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		seen[e.OrderID] = append(seen[e.OrderID], e.Seq)
+		return nil
+	})
+
+	p := typemux.NewPartitionedDispatcher(reg.Seal(), typemux.PartitionConfig{Lanes: 4, LaneSize: 8})
+
+	const orders, perOrder = 8, 50
+	for seq := range perOrder {
+		for o := range orders {
+			if _, err := p.Submit(context.Background(), orderEvent{OrderID: fmt.Sprint("ord-", o), Seq: seq}); err != nil {
+				t.Fatalf("unexpected submit error: %v", err)
+			}
+		}
+	}
+
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected shutdown error: %v", err)
+	}
+
+	want := make([]int, perOrder)
+	for i := range want {
+		want[i] = i
+	}
+	for o := range orders {
+		id := fmt.Sprint("ord-", o)
+		if !reflect.DeepEqual(seen[id], want) {
+			t.Errorf("%s: events out of order: %v", id, seen[id])
+		}
+	}
+}
+
+func TestPartitionedDispatcher_RegisteredKey(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	var mu sync.Mutex
+	var got []int
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e customerEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, e.Seq)
+		return nil
+	})
+
+	p := typemux.NewPartitionedDispatcher(reg, typemux.PartitionConfig{Lanes: 2})
+	typemux.RegisterPartitionKey(p, func(e customerEvent) string { return e.CustomerID })
+
+	var last *typemux.Future
+	for seq := range 20 {
+		f, err := p.Submit(context.Background(), &customerEvent{CustomerID: "c1", Seq: seq})
+		if err != nil {
+			t.Fatalf("unexpected submit error: %v", err)
+		}
+		last = f
+	}
+	if err := last.Wait(context.Background()); err != nil {
+		t.Fatalf("unexpected dispatch error: %v", err)
+	}
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected shutdown error: %v", err)
+	}
+
+	for i, seq := range got {
+		if seq != i {
+			t.Fatalf("events out of order: %v", got)
+		}
+	}
+}
+
+func TestPartitionedDispatcher_NoKey(t *testing.T) {
+	p := typemux.NewPartitionedDispatcher(typemux.NewRegistry(), typemux.PartitionConfig{Lanes: 1})
+	defer func() { _ = p.Shutdown(context.Background()) }()
+
+	if _, err := p.Submit(context.Background(), testEvent{}); !errors.Is(err, typemux.ErrNoPartitionKey) {
+		t.Errorf("expected ErrNoPartitionKey, got %v", err)
+	}
+}
+
+func TestPartitionedDispatcher_PointerKey(t *testing.T) {
+	p := typemux.NewPartitionedDispatcher(typemux.NewRegistry(), typemux.PartitionConfig{Lanes: 1})
+	defer func() { _ = p.Shutdown(context.Background()) }()
+	typemux.RegisterPartitionKey(p, func(e customerEvent) string { return e.CustomerID })
+
+	// A nil pointer has no value to extract a key from.
+	if _, err := p.Submit(context.Background(), (*customerEvent)(nil)); !errors.Is(err, typemux.ErrNoPartitionKey) {
+		t.Errorf("expected ErrNoPartitionKey for a nil pointer, got %v", err)
+	}
+
+	exact := typemux.NewPartitionedDispatcher(
+		typemux.NewRegistry(typemux.WithPointerPolicy(typemux.PointerExact)),
+		typemux.PartitionConfig{Lanes: 1},
+	)
+	defer func() { _ = exact.Shutdown(context.Background()) }()
+	typemux.RegisterPartitionKey(exact, func(e customerEvent) string { return e.CustomerID })
+
+	if _, err := exact.Submit(context.Background(), &customerEvent{CustomerID: "c1"}); !errors.Is(err, typemux.ErrNoPartitionKey) {
+		t.Errorf("expected ErrNoPartitionKey under PointerExact, got %v", err)
+	}
+}
+
+func TestPartitionedDispatcher_Closed(t *testing.T) {
+	p := typemux.NewPartitionedDispatcher(typemux.NewRegistry(), typemux.PartitionConfig{Lanes: 1})
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected shutdown error: %v", err)
+	}
+
+	if _, err := p.Submit(context.Background(), orderEvent{OrderID: "o1"}); !errors.Is(err, typemux.ErrDispatcherClosed) {
+		t.Errorf("expected ErrDispatcherClosed, got %v", err)
+	}
+}
+
+func TestPartitionedDispatcher_ShutdownReleasesBlockedSubmit(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	defer close(release)
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e orderEvent) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		return nil
+	})
+
+	p := typemux.NewPartitionedDispatcher(reg, typemux.PartitionConfig{Lanes: 1})
+	if _, err := p.Submit(context.Background(), orderEvent{OrderID: "o1"}); err != nil {
+		t.Fatalf("unexpected submit error: %v", err)
+	}
+	<-started
+
+	blocked := newWaitingContext()
+	submitted := make(chan error, 1)
+	go func() {
+		_, err := p.Submit(blocked, orderEvent{OrderID: "o1", Seq: 1})
+		submitted <- err
+	}()
+	<-blocked.waiting // Submit is waiting for room in the lane
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- p.Shutdown(ctx) }()
+
+	select {
+	case err := <-shutdown:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown did not honour its context")
+	}
+	if err := <-submitted; !errors.Is(err, typemux.ErrDispatcherClosed) {
+		t.Errorf("expected ErrDispatcherClosed, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package typemux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// PublishPolicy controls how Publish invokes the subscribers of a type.
+type PublishPolicy int
+
+const (
+	// PublishSequential invokes subscribers one after another on the caller's
+	// goroutine, in registration order.
+	PublishSequential PublishPolicy = iota
+	// PublishConcurrent invokes every subscriber on its own goroutine and
+	// waits for all of them to return.
+	PublishConcurrent
+)
+
+type subscribeRegistry interface {
+	registerSubscriber(reflect.Type, handlerFuncAny)
+}
+
+type publisher interface {
+	subscribers(typ reflect.Type) []handlerFuncAny
+	pointerPolicy() PointerPolicy
+}
+
+// Subscribe adds a subscriber for values of type T, with optional middleware.
+//
+// Unlike RegisterDispatch, subscribers accumulate: every handler subscribed to
+// T is invoked by Publish. Subscribers are independent of dispatch handlers —
+// Dispatch never calls them, and Publish never calls dispatch handlers.
+//
+// Middleware is applied outermost first, as with RegisterDispatch.
+func Subscribe[T any](reg subscribeRegistry, handler HandlerFunc[T], middleware ...Middleware[T]) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	finalTyped := applyMiddleware(handler, middleware...)
+
+	reg.registerSubscriber(typ, wrapTypedHandler(finalTyped))
+}
+
+// Publish delivers the given value to every subscriber registered for its
+// concrete type, following the given policy. Pointer fallbacks follow the
+// registry's PointerPolicy, as in Dispatch.
+//
+// Every subscriber runs even if an earlier one fails; their errors are
+// aggregated with errors.Join in registration order. Optional generic
+// middleware wraps the whole publish, outermost-first.
+//
+// It returns ErrHandlerNotFound if no subscriber is registered for the value's type.
+func Publish(pub publisher, ctx context.Context, v any, policy PublishPolicy, middleware ...DispatchMiddleware) error {
+	typ := reflect.TypeOf(v)
+
+	subs, v, ok := lookupPointer(typ, v, pub.pointerPolicy(), func(t reflect.Type) ([]handlerFuncAny, bool) {
+		subs := pub.subscribers(t)
+		return subs, len(subs) > 0
+	})
+	if !ok {
+		return fmt.Errorf("typemux: %w for type %v", ErrHandlerNotFound, typ)
+	}
+
+	return chain(ctx, v, middleware, func(ctx context.Context) error {
+		return publish(ctx, v, subs, policy)
+	})
+}
+
+func publish(ctx context.Context, v any, subs []handlerFuncAny, policy PublishPolicy) error {
+	errs := make([]error, len(subs))
+
+	switch policy {
+	case PublishConcurrent:
+		var wg sync.WaitGroup
+		wg.Add(len(subs))
+		for i, sub := range subs {
+			go func() {
+				defer wg.Done()
+				errs[i] = sub(ctx, v)
+			}()
+		}
+		wg.Wait()
+	default:
+		for i, sub := range subs {
+			errs[i] = sub(ctx, v)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,148 @@
+package typemux_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/struct0x/typemux"
+)
+
+func TestPublish_InvokesAllSubscribers(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	var order []string
+	typemux.Subscribe(reg, func(ctx context.Context, e testEvent) error {
+		order = append(order, "projection:"+e.Name)
+		return nil
+	})
+	typemux.Subscribe(reg, func(ctx context.Context, e testEvent) error {
+		order = append(order, "notifier:"+e.Name)
+		return nil
+	})
+
+	t.Run("standard_registry", func(t *testing.T) {
+		order = nil
+		if err := typemux.Publish(reg, context.Background(), testEvent{Name: "a"}, typemux.PublishSequential); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := []string{"projection:a", "notifier:a"}
+		if !reflect.DeepEqual(order, expected) {
+			t.Errorf("expected %v, got %v", expected, order)
+		}
+	})
+
+	t.Run("sealed_registry", func(t *testing.T) {
+		order = nil
+		if err := typemux.Publish(reg.Seal(), context.Background(), &testEvent{Name: "b"}, typemux.PublishSequential); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := []string{"projection:b", "notifier:b"}
+		if !reflect.DeepEqual(order, expected) {
+			t.Errorf("expected %v, got %v", expected, order)
+		}
+	})
+}
+
+func TestPublish_JoinsErrors(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	errA := errors.New("a failed")
+	errB := errors.New("b failed")
+	var ran atomic.Int32
+
+	typemux.Subscribe(reg, func(ctx context.Context, e testEvent) error {
+		ran.Add(1)
+		return errA
+	})
+	typemux.Subscribe(reg, func(ctx context.Context, e testEvent) error {
+		ran.Add(1)
+		return nil
+	})
+	typemux.Subscribe(reg, func(ctx context.Context, e testEvent) error {
+		ran.Add(1)
+		return errB
+	})
+
+	for _, policy := range []typemux.PublishPolicy{typemux.PublishSequential, typemux.PublishConcurrent} {
+		ran.Store(0)
+
+		err := typemux.Publish(reg, context.Background(), testEvent{}, policy)
+		if !errors.Is(err, errA) || !errors.Is(err, errB) {
+			t.Errorf("policy %d: expected both errors, got %v", policy, err)
+		}
+		if ran.Load() != 3 {
+			t.Errorf("policy %d: expected 3 subscribers to run, got %d", policy, ran.Load())
+		}
+	}
+}
+
+func TestPublish_NoSubscribers(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	// A dispatch handler is not a subscriber.
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error {
+		return nil
+	})
+
+	err := typemux.Publish(reg, context.Background(), testEvent{}, typemux.PublishSequential)
+	if !errors.Is(err, typemux.ErrHandlerNotFound) {
+		t.Errorf("expected ErrHandlerNotFound, got %v", err)
+	}
+}
+
+func TestPublish_SealedUnaffectedByLaterSubscribe(t *testing.T) {
+	reg := typemux.NewDispatchRegistry()
+
+	var calls atomic.Int32
+	typemux.Subscribe(reg, func(ctx context.Context, e testEvent) error {
+		calls.Add(1)
+		return nil
+	})
+
+	sealed := reg.Seal()
+
+	typemux.Subscribe(reg, func(ctx context.Context, e testEvent) error {
+		calls.Add(1)
+		return nil
+	})
+
+	if err := typemux.Publish(sealed, context.Background(), testEvent{}, typemux.PublishConcurrent); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected 1 subscriber call, got %d", calls.Load())
+	}
+}
+
+func TestPublish_GenericMiddleware(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	var order []string
+	typemux.Subscribe(reg, func(ctx context.Context, e testEvent) error {
+		order = append(order, "sub1")
+		return nil
+	})
+	typemux.Subscribe(reg, func(ctx context.Context, e testEvent) error {
+		order = append(order, "sub2")
+		return nil
+	})
+
+	mw := func(ctx context.Context, event any, next func(context.Context) error) error {
+		order = append(order, "before")
+		err := next(ctx)
+		order = append(order, "after")
+		return err
+	}
+
+	if err := typemux.Publish(reg, context.Background(), testEvent{}, typemux.PublishSequential, mw); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"before", "sub1", "sub2", "after"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("expected %v, got %v", expected, order)
+	}
+}
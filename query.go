@@ -0,0 +1,105 @@
+package typemux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrResponseTypeMismatch is returned when Ask is invoked with a Resp type
+// parameter that does not match the response type used at registration time.
+var ErrResponseTypeMismatch = errors.New("response type mismatch")
+
+// QueryFunc is a type-safe query handler: it answers a request of type Req
+// with a response of type Resp.
+type QueryFunc[Req, Resp any] func(ctx context.Context, req Req) (Resp, error)
+
+// QueryMiddleware is a type-safe wrapper around a QueryFunc, the query
+// counterpart of Middleware.
+type QueryMiddleware[Req, Resp any] func(next QueryFunc[Req, Resp]) QueryFunc[Req, Resp]
+
+type queryEntry struct {
+	resp reflect.Type
+	fn   any // QueryFunc[Req, Resp]
+	// guarded returns fn wrapped in panic recovery, for WithRecover.
+	guarded func(cfg *RecoverConfig) any
+}
+
+type queryRegistry interface {
+	registerQuery(reqType reflect.Type, entry queryEntry)
+}
+
+type querier interface {
+	query(reqType reflect.Type) (queryEntry, bool)
+}
+
+// RegisterQuery adds a query handler for requests of type Req, with optional
+// middleware. Queries live next to dispatch handlers in the same registry and
+// are carried over by Seal.
+//
+// If a handler for the same Req has already been registered, it will be
+// replaced — whatever its response type.
+//
+// Middleware is applied outermost first, as with RegisterDispatch.
+func RegisterQuery[Req, Resp any](reg queryRegistry, handler QueryFunc[Req, Resp], middleware ...QueryMiddleware[Req, Resp]) {
+	reqType := reflect.TypeOf((*Req)(nil)).Elem()
+	respType := reflect.TypeOf((*Resp)(nil)).Elem()
+
+	final := handler
+	for i := len(middleware) - 1; i >= 0; i-- {
+		final = middleware[i](final)
+	}
+
+	reg.registerQuery(reqType, queryEntry{
+		resp: respType,
+		fn:   final,
+		guarded: func(cfg *RecoverConfig) any {
+			return QueryFunc[Req, Resp](func(ctx context.Context, req Req) (Resp, error) {
+				var resp Resp
+				err := cfg.guard(ctx, reflect.TypeOf(req), func(ctx context.Context) error {
+					var err error
+					resp, err = final(ctx, req)
+					return err
+				})
+				return resp, err
+			})
+		},
+	})
+}
+
+// Ask sends req to the query handler registered for Req and returns its
+// response. The handler is looked up by the static type Req, so no pointer
+// fallback applies. Optional generic middleware is applied outermost-first,
+// as with Dispatch; it receives req as the event.
+//
+// Returns:
+//   - ErrHandlerNotFound if no query handler is registered for Req
+//   - ErrResponseTypeMismatch if the handler was registered with a different Resp
+func Ask[Req, Resp any](reg querier, ctx context.Context, req Req, middleware ...DispatchMiddleware) (Resp, error) {
+	var zero Resp
+
+	reqType := reflect.TypeOf((*Req)(nil)).Elem()
+	entry, ok := reg.query(reqType)
+	if !ok {
+		return zero, fmt.Errorf("typemux: %w for query type %v", ErrHandlerNotFound, reqType)
+	}
+
+	handler, ok := entry.fn.(QueryFunc[Req, Resp])
+	if !ok {
+		respType := reflect.TypeOf((*Resp)(nil)).Elem()
+		return zero, fmt.Errorf("typemux: %w: query %v is registered with response %v, requested %v", ErrResponseTypeMismatch, reqType, entry.resp, respType)
+	}
+
+	if len(middleware) == 0 {
+		return handler(ctx, req)
+	}
+
+	var resp Resp
+	err := chain(ctx, req, middleware, func(ctx context.Context) error {
+		var err error
+		resp, err = handler(ctx, req)
+		return err
+	})
+	return resp, err
+}
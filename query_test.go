@@ -0,0 +1,142 @@
+package typemux_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/struct0x/typemux"
+)
+
+type getUser struct {
+	ID string
+}
+
+type userView struct {
+	ID   string
+	Name string
+}
+
+func TestAsk_ReturnsResponse(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	typemux.RegisterQuery(reg, func(ctx context.Context, q getUser) (userView, error) {
+		return userView{ID: q.ID, Name: "Alice"}, nil
+	})
+
+	t.Run("standard_registry", func(t *testing.T) {
+		got, err := typemux.Ask[getUser, userView](reg, context.Background(), getUser{ID: "u1"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != (userView{ID: "u1", Name: "Alice"}) {
+			t.Errorf("unexpected response: %+v", got)
+		}
+	})
+
+	t.Run("sealed_registry", func(t *testing.T) {
+		got, err := typemux.Ask[getUser, userView](reg.Seal(), context.Background(), getUser{ID: "u2"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.ID != "u2" {
+			t.Errorf("unexpected response: %+v", got)
+		}
+	})
+}
+
+func TestAsk_NotFound(t *testing.T) {
+	reg := typemux.NewDispatchRegistry()
+
+	// A dispatch handler for the same type does not answer queries.
+	typemux.RegisterDispatch(reg, func(ctx context.Context, q getUser) error { return nil })
+
+	_, err := typemux.Ask[getUser, userView](reg, context.Background(), getUser{})
+	if !errors.Is(err, typemux.ErrHandlerNotFound) {
+		t.Errorf("expected ErrHandlerNotFound, got %v", err)
+	}
+}
+
+func TestAsk_ResponseTypeMismatch(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	typemux.RegisterQuery(reg, func(ctx context.Context, q getUser) (userView, error) {
+		return userView{}, nil
+	})
+
+	_, err := typemux.Ask[getUser, string](reg.Seal(), context.Background(), getUser{})
+	if !errors.Is(err, typemux.ErrResponseTypeMismatch) {
+		t.Fatalf("expected ErrResponseTypeMismatch, got %v", err)
+	}
+	const want = "typemux: response type mismatch: query typemux_test.getUser is registered with response typemux_test.userView, requested string"
+	if err.Error() != want {
+		t.Errorf("unexpected message:\n got: %s\nwant: %s", err, want)
+	}
+}
+
+func TestAsk_Middleware(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	var order []string
+
+	cache := func(next typemux.QueryFunc[getUser, userView]) typemux.QueryFunc[getUser, userView] {
+		return func(ctx context.Context, q getUser) (userView, error) {
+			order = append(order, "typed")
+			if q.ID == "cached" {
+				return userView{ID: q.ID, Name: "from cache"}, nil
+			}
+			return next(ctx, q)
+		}
+	}
+
+	typemux.RegisterQuery(reg, func(ctx context.Context, q getUser) (userView, error) {
+		order = append(order, "handler")
+		return userView{ID: q.ID}, nil
+	}, cache)
+
+	generic := func(ctx context.Context, event any, next func(context.Context) error) error {
+		order = append(order, "generic:"+reflect.TypeOf(event).Name())
+		return next(ctx)
+	}
+
+	got, err := typemux.Ask[getUser, userView](reg, context.Background(), getUser{ID: "cached"}, generic)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Name != "from cache" {
+		t.Errorf("expected cached response, got %+v", got)
+	}
+
+	expected := []string{"generic:getUser", "typed"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("expected %v, got %v", expected, order)
+	}
+
+	order = nil
+	if _, err := typemux.Ask[getUser, userView](reg, context.Background(), getUser{ID: "u1"}, generic); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected = []string{"generic:getUser", "typed", "handler"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("expected %v, got %v", expected, order)
+	}
+}
+
+func TestAsk_HandlerError(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	errNotFound := errors.New("user not found")
+	typemux.RegisterQuery(reg, func(ctx context.Context, q getUser) (userView, error) {
+		return userView{}, errNotFound
+	})
+
+	mw := func(ctx context.Context, event any, next func(context.Context) error) error {
+		return next(ctx)
+	}
+
+	_, err := typemux.Ask[getUser, userView](reg, context.Background(), getUser{}, mw)
+	if !errors.Is(err, errNotFound) {
+		t.Errorf("expected handler error, got %v", err)
+	}
+}
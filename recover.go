@@ -0,0 +1,130 @@
+package typemux
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"slices"
+)
+
+// PanicError is returned in place of a panic recovered from a handler.
+type PanicError struct {
+	// Type is the concrete type of the value being handled; for a batch
+	// handler, the type of its elements.
+	Type reflect.Type
+	// Value is the value passed to panic.
+	Value any
+	// Stack is the stack trace of the panicking goroutine.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("typemux: panic handling %v: %v", e.Type, e.Value)
+}
+
+// Unwrap returns the panic value if it is an error, so errors.Is and
+// errors.As see through a PanicError.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// RecoverConfig configures panic recovery.
+type RecoverConfig struct {
+	// Repanic, if set, selects panics to propagate instead of converting
+	// them; they are re-raised with the original value.
+	Repanic func(p *PanicError) bool
+}
+
+// RepanicFor returns a RecoverConfig.Repanic selecting panics raised while
+// handling values of the given types.
+func RepanicFor(types ...reflect.Type) func(p *PanicError) bool {
+	return func(p *PanicError) bool {
+		return slices.Contains(types, p.Type)
+	}
+}
+
+// RecoverDispatch returns a DispatchMiddleware converting panics in the
+// dispatch it wraps into a *PanicError.
+func RecoverDispatch(cfg RecoverConfig) DispatchMiddleware {
+	return func(ctx context.Context, event any, next func(context.Context) error) error {
+		return cfg.guard(ctx, reflect.TypeOf(event), next)
+	}
+}
+
+// Recover returns a typed Middleware converting panics in the handler for T
+// into a *PanicError.
+func Recover[T any](cfg RecoverConfig) Middleware[T] {
+	return func(next HandlerFunc[T]) HandlerFunc[T] {
+		return func(ctx context.Context, val T) error {
+			return cfg.guard(ctx, reflect.TypeOf(val), func(ctx context.Context) error {
+				return next(ctx, val)
+			})
+		}
+	}
+}
+
+// WithRecover converts panics into a *PanicError in every dispatch handler,
+// fallback, subscriber, batch handler and query handler registered on the
+// registry, including the middleware they were registered with.
+// Registry-level middleware added with Use runs outside it.
+func WithRecover(cfg RecoverConfig) Option {
+	return func(o *options) {
+		o.recover = &cfg
+	}
+}
+
+// recovering wraps handler in the panic recovery configured by the options,
+// if any.
+func (o *options) recovering(handler handlerFuncAny) handlerFuncAny {
+	if o.recover == nil {
+		return handler
+	}
+	cfg := o.recover
+	return func(ctx context.Context, v any) error {
+		return cfg.guard(ctx, reflect.TypeOf(v), func(ctx context.Context) error {
+			return handler(ctx, v)
+		})
+	}
+}
+
+// recoveringBatch wraps the batch handler for values of typ in the panic
+// recovery configured by the options, if any.
+func (o *options) recoveringBatch(typ reflect.Type, handler batchFuncAny) batchFuncAny {
+	if o.recover == nil {
+		return handler
+	}
+	cfg := o.recover
+	return func(ctx context.Context, vals []any) error {
+		return cfg.guard(ctx, typ, func(ctx context.Context) error {
+			return handler(ctx, vals)
+		})
+	}
+}
+
+// recoveringQuery wraps the query handler of entry in the panic recovery
+// configured by the options, if any.
+func (o *options) recoveringQuery(entry queryEntry) queryEntry {
+	if o.recover == nil {
+		return entry
+	}
+	entry.fn = entry.guarded(o.recover)
+	return entry
+}
+
+func (c *RecoverConfig) guard(ctx context.Context, typ reflect.Type, call func(context.Context) error) (err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		p := &PanicError{Type: typ, Value: r, Stack: debug.Stack()}
+		if c.Repanic != nil && c.Repanic(p) {
+			panic(r)
+		}
+		err = p
+	}()
+
+	return call(ctx)
+}
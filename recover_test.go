@@ -0,0 +1,128 @@
+package typemux_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/struct0x/typemux"
+)
+
+var errCorrupt = errors.New("corrupt state")
+
+func TestRecover_Middleware(t *testing.T) {
+	reg := typemux.NewRegistry()
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error {
+		panic(errCorrupt)
+	}, typemux.Recover[testEvent](typemux.RecoverConfig{}))
+	typemux.RegisterDispatch(reg, func(ctx context.Context, o OrderPlaced) error {
+		panic("boom")
+	})
+
+	err := typemux.Dispatch(reg, context.Background(), testEvent{})
+	var p *typemux.PanicError
+	if !errors.As(err, &p) {
+		t.Fatalf("expected *PanicError, got %v", err)
+	}
+	if p.Type != reflect.TypeOf(testEvent{}) || p.Value != errCorrupt {
+		t.Errorf("unexpected panic error: %+v", p)
+	}
+	if !strings.Contains(string(p.Stack), "recover_test.go") {
+		t.Errorf("expected the stack of the panic, got %s", p.Stack)
+	}
+	if !errors.Is(err, errCorrupt) {
+		t.Errorf("expected an error panic value to unwrap, got %v", err)
+	}
+
+	err = typemux.Dispatch(reg, context.Background(), OrderPlaced{}, typemux.RecoverDispatch(typemux.RecoverConfig{}))
+	const want = "typemux: panic handling typemux_test.OrderPlaced: boom"
+	if err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+}
+
+func TestWithRecover(t *testing.T) {
+	reg := typemux.NewRegistry(typemux.WithRecover(typemux.RecoverConfig{}))
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error {
+		panic("handler")
+	})
+	typemux.RegisterFallback(reg, func(ctx context.Context, v any) error {
+		panic("fallback")
+	})
+	typemux.Subscribe(reg, func(ctx context.Context, o OrderPlaced) error {
+		panic("subscriber")
+	})
+
+	for name, disp := range map[string]func(v any) error{
+		"standard_registry": func(v any) error { return typemux.Dispatch(reg, context.Background(), v) },
+		"sealed_registry":   func(v any) error { return typemux.Dispatch(reg.Seal(), context.Background(), v) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			var p *typemux.PanicError
+			if err := disp(testEvent{}); !errors.As(err, &p) || p.Value != "handler" {
+				t.Errorf("expected handler panic recovered, got %v", err)
+			}
+			if err := disp(UserCreated{}); !errors.As(err, &p) || p.Value != "fallback" || p.Type != reflect.TypeOf(UserCreated{}) {
+				t.Errorf("expected fallback panic recovered, got %v", err)
+			}
+		})
+	}
+
+	var p *typemux.PanicError
+	if err := typemux.Publish(reg, context.Background(), OrderPlaced{}, typemux.PublishConcurrent); !errors.As(err, &p) {
+		t.Errorf("expected subscriber panic recovered, got %v", err)
+	}
+}
+
+func TestWithRecover_BatchAndQuery(t *testing.T) {
+	reg := typemux.NewRegistry(typemux.WithRecover(typemux.RecoverConfig{}))
+	typemux.RegisterBatchDispatch(reg, func(ctx context.Context, batch []testEvent) error {
+		panic("batch")
+	})
+	typemux.RegisterQuery(reg, func(ctx context.Context, q getUser) (userView, error) {
+		panic("query")
+	})
+
+	t.Run("standard_registry", func(t *testing.T) {
+		var p *typemux.PanicError
+		if err := typemux.DispatchBatch(reg, context.Background(), []any{testEvent{}}); !errors.As(err, &p) || p.Value != "batch" || p.Type != reflect.TypeOf(testEvent{}) {
+			t.Errorf("expected batch panic recovered, got %v", err)
+		}
+		if _, err := typemux.Ask[getUser, userView](reg, context.Background(), getUser{}); !errors.As(err, &p) || p.Value != "query" || p.Type != reflect.TypeOf(getUser{}) {
+			t.Errorf("expected query panic recovered, got %v", err)
+		}
+	})
+
+	t.Run("sealed_registry", func(t *testing.T) {
+		sealed := reg.Seal()
+
+		var p *typemux.PanicError
+		if err := typemux.DispatchBatch(sealed, context.Background(), []any{testEvent{}}); !errors.As(err, &p) || p.Value != "batch" {
+			t.Errorf("expected batch panic recovered, got %v", err)
+		}
+		if _, err := typemux.Ask[getUser, userView](sealed, context.Background(), getUser{}); !errors.As(err, &p) || p.Value != "query" {
+			t.Errorf("expected query panic recovered, got %v", err)
+		}
+	})
+}
+
+func TestRecover_Repanic(t *testing.T) {
+	cfg := typemux.RecoverConfig{Repanic: typemux.RepanicFor(reflect.TypeOf(OrderPlaced{}))}
+	reg := typemux.NewRegistry(typemux.WithRecover(cfg))
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error { panic("recovered") })
+	typemux.RegisterDispatch(reg, func(ctx context.Context, o OrderPlaced) error { panic("propagated") })
+
+	if err := typemux.Dispatch(reg, context.Background(), testEvent{}); !errors.As(err, new(*typemux.PanicError)) {
+		t.Errorf("expected *PanicError, got %v", err)
+	}
+
+	defer func() {
+		if r := recover(); r != "propagated" {
+			t.Errorf("expected the original panic value, got %v", r)
+		}
+	}()
+	_ = typemux.Dispatch(reg, context.Background(), OrderPlaced{})
+	t.Error("expected a panic")
+}
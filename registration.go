@@ -0,0 +1,25 @@
+package typemux
+
+// Registration is a handle to a single registration made by RegisterDispatch
+// or RegisterCodec. It lets a plugin or tenant remove exactly what it
+// registered from a live registry.
+//
+// Registries sealed before Unregister keep the registration: Seal takes a
+// snapshot.
+type Registration struct {
+	unregister func() bool
+}
+
+// Unregister removes the registration from its registry. It reports whether
+// anything was removed: it does nothing and returns false if the handler or
+// codec has since been replaced by a later registration, or was already
+// unregistered.
+//
+// Unregister is safe to call concurrently with dispatch and registration.
+// The zero Registration is valid and Unregister on it returns false.
+func (r Registration) Unregister() bool {
+	if r.unregister == nil {
+		return false
+	}
+	return r.unregister()
+}
@@ -0,0 +1,117 @@
+package typemux_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/struct0x/typemux"
+)
+
+func TestRegistration_UnregisterDispatch(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	h := typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error { return nil })
+	sealed := reg.Seal()
+
+	if !h.Unregister() {
+		t.Fatal("expected Unregister to remove the handler")
+	}
+	if h.Unregister() {
+		t.Error("expected second Unregister to be a no-op")
+	}
+
+	if err := typemux.Dispatch(reg, context.Background(), testEvent{}); !errors.Is(err, typemux.ErrHandlerNotFound) {
+		t.Errorf("expected ErrHandlerNotFound, got %v", err)
+	}
+	if err := typemux.Dispatch(sealed, context.Background(), testEvent{}); err != nil {
+		t.Errorf("expected sealed registry to keep the handler, got %v", err)
+	}
+}
+
+func TestRegistration_KeepsReplacement(t *testing.T) {
+	reg := typemux.NewDispatchRegistry()
+
+	var out string
+	first := typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error {
+		out = "first"
+		return nil
+	})
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error {
+		out = "second"
+		return nil
+	})
+
+	if first.Unregister() {
+		t.Error("expected stale registration not to remove its replacement")
+	}
+
+	if err := typemux.Dispatch(reg, context.Background(), testEvent{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "second" {
+		t.Errorf("expected replacement handler, got: %s", out)
+	}
+}
+
+func TestRegistration_UnregisterInterfaceHandler(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	h := typemux.RegisterDispatch(reg, func(ctx context.Context, a auditable) error { return nil })
+	h.Unregister()
+
+	if err := typemux.Dispatch(reg, context.Background(), auditedEvent{}); !errors.Is(err, typemux.ErrHandlerNotFound) {
+		t.Errorf("expected ErrHandlerNotFound, got %v", err)
+	}
+}
+
+func TestRegistration_UnregisterCodec(t *testing.T) {
+	reg := typemux.NewRegistry()
+
+	typemux.RegisterCodec(reg, "user_created", typemux.NewCodec(
+		func(u UserCreated) (map[string]any, error) { return map[string]any{"id": u.ID}, nil },
+		func(m map[string]any) (UserCreated, error) { return UserCreated{}, nil },
+	))
+	h := typemux.RegisterCodec(reg, "user_created", typemux.JSONCodec[UserCreated]())
+	sealed := reg.Seal()
+
+	if !h.Unregister() {
+		t.Fatal("expected Unregister to remove the codec")
+	}
+
+	// The []byte codec is gone, the map codec under the same key stays.
+	if _, err := typemux.CreateType(reg, "user_created", []byte(`{}`)); !errors.Is(err, typemux.ErrDataTypeNotSupported) {
+		t.Errorf("expected ErrDataTypeNotSupported, got %v", err)
+	}
+	if _, _, err := typemux.Serialize[string, []byte](reg, UserCreated{}); !errors.Is(err, typemux.ErrDataTypeMismatch) {
+		t.Errorf("expected ErrDataTypeMismatch, got %v", err)
+	}
+	if _, _, err := typemux.Serialize[string, map[string]any](reg, UserCreated{}); err != nil {
+		t.Errorf("expected map codec to survive, got %v", err)
+	}
+
+	if _, err := typemux.CreateType(sealed, "user_created", []byte(`{}`)); err != nil {
+		t.Errorf("expected sealed registry to keep the codec, got %v", err)
+	}
+}
+
+func TestRegistration_UnregisterLastCodec(t *testing.T) {
+	reg := typemux.NewCodecRegistry()
+
+	h := typemux.RegisterCodec(reg, "user_created", typemux.JSONCodec[UserCreated]())
+	h.Unregister()
+
+	if _, err := typemux.CreateType(reg, "user_created", []byte(`{}`)); !errors.Is(err, typemux.ErrFactoryNotFound) {
+		t.Errorf("expected ErrFactoryNotFound, got %v", err)
+	}
+	if _, _, err := typemux.Serialize[string, []byte](reg, UserCreated{}); !errors.Is(err, typemux.ErrSerializerNotFound) {
+		t.Errorf("expected ErrSerializerNotFound, got %v", err)
+	}
+}
+
+func TestRegistration_Zero(t *testing.T) {
+	var h typemux.Registration
+	if h.Unregister() {
+		t.Error("expected zero Registration to be a no-op")
+	}
+}
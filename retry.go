@@ -0,0 +1,171 @@
+package typemux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// Retry defaults, used when RetryConfig leaves a field zero.
+const (
+	DefaultRetryAttempts   = 3
+	DefaultRetryBaseDelay  = 100 * time.Millisecond
+	DefaultRetryMaxDelay   = 10 * time.Second
+	DefaultRetryMultiplier = 2.0
+)
+
+// RetryClassifier reports whether a handler error is worth another attempt.
+type RetryClassifier func(err error) bool
+
+// RetryConfig configures the Retry and RetryDispatch middleware.
+type RetryConfig struct {
+	// MaxAttempts is the total number of attempts, the first included.
+	// Defaults to DefaultRetryAttempts.
+	MaxAttempts int
+	// BaseDelay is the wait before the second attempt. Defaults to
+	// DefaultRetryBaseDelay.
+	BaseDelay time.Duration
+	// MaxDelay caps the wait between attempts. Defaults to
+	// DefaultRetryMaxDelay.
+	MaxDelay time.Duration
+	// Multiplier grows the wait after each attempt. Defaults to
+	// DefaultRetryMultiplier.
+	Multiplier float64
+	// Jitter randomizes each wait by up to this fraction in either
+	// direction, e.g. 0.2 for ±20%. Zero disables jitter.
+	Jitter float64
+	// Classify decides which errors are retried. Defaults to IsRetryable.
+	Classify RetryClassifier
+	// Clock is used to wait between attempts. Defaults to the system clock.
+	Clock Clock
+}
+
+// RetryExhaustedError is returned when every attempt allowed by
+// RetryConfig.MaxAttempts failed with a retryable error. It unwraps to the
+// last error.
+type RetryExhaustedError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *RetryExhaustedError) Error() string {
+	return fmt.Sprintf("typemux: giving up after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *RetryExhaustedError) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryable is the default RetryClassifier. It retries every error except
+// lookup failures (ErrHandlerNotFound, ErrAmbiguousHandler or a *DispatchError
+// in PhaseLookup), context cancellation and errors implementing
+// interface{ Retryable() bool } that report false.
+func IsRetryable(err error) bool {
+	if errors.Is(err, ErrHandlerNotFound) || errors.Is(err, ErrAmbiguousHandler) {
+		return false
+	}
+	var de *DispatchError
+	if errors.As(err, &de) && de.Phase == PhaseLookup {
+		return false
+	}
+
+	var r interface{ Retryable() bool }
+	if errors.As(err, &r) {
+		return r.Retryable()
+	}
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// RetryOn returns a RetryClassifier that retries only errors matching one of
+// targets under errors.Is.
+func RetryOn(targets ...error) RetryClassifier {
+	return func(err error) bool {
+		for _, target := range targets {
+			if errors.Is(err, target) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// RetryDispatch returns a DispatchMiddleware that retries failed dispatches
+// with exponential backoff.
+//
+// A non-retryable error is returned as is. Once MaxAttempts is used up, the
+// last error is returned in a *RetryExhaustedError. If ctx is done while
+// waiting, the wait is cut short and the context's error is returned joined
+// with the last handler error.
+func RetryDispatch(cfg RetryConfig) DispatchMiddleware {
+	r := newRetrier(cfg)
+	return func(ctx context.Context, event any, next func(context.Context) error) error {
+		return r.do(ctx, next)
+	}
+}
+
+// Retry returns a typed Middleware retrying failed calls of the handler for T,
+// with the same behavior as RetryDispatch.
+func Retry[T any](cfg RetryConfig) Middleware[T] {
+	r := newRetrier(cfg)
+	return func(next HandlerFunc[T]) HandlerFunc[T] {
+		return func(ctx context.Context, val T) error {
+			return r.do(ctx, func(ctx context.Context) error {
+				return next(ctx, val)
+			})
+		}
+	}
+}
+
+type retrier struct {
+	cfg   RetryConfig
+	clock Clock
+}
+
+func newRetrier(cfg RetryConfig) *retrier {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultRetryAttempts
+	}
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = DefaultRetryBaseDelay
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = DefaultRetryMaxDelay
+	}
+	if cfg.Multiplier <= 0 {
+		cfg.Multiplier = DefaultRetryMultiplier
+	}
+	if cfg.Classify == nil {
+		cfg.Classify = IsRetryable
+	}
+	return &retrier{cfg: cfg, clock: clockOrSystem(cfg.Clock)}
+}
+
+func (r *retrier) do(ctx context.Context, call func(context.Context) error) error {
+	delay := min(r.cfg.BaseDelay, r.cfg.MaxDelay)
+	for attempt := 1; ; attempt++ {
+		err := call(ctx)
+		if err == nil || !r.cfg.Classify(err) {
+			return err
+		}
+		if attempt >= r.cfg.MaxAttempts {
+			return &RetryExhaustedError{Attempts: attempt, Err: err}
+		}
+
+		select {
+		case <-r.clock.After(r.jitter(delay)):
+		case <-ctx.Done():
+			return errors.Join(ctx.Err(), err)
+		}
+
+		delay = min(time.Duration(float64(delay)*r.cfg.Multiplier), r.cfg.MaxDelay)
+	}
+}
+
+func (r *retrier) jitter(d time.Duration) time.Duration {
+	if r.cfg.Jitter <= 0 {
+		return d
+	}
+	return time.Duration(float64(d) * (1 + r.cfg.Jitter*(2*rand.Float64()-1)))
+}
@@ -0,0 +1,207 @@
+package typemux_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/struct0x/typemux"
+)
+
+// fakeClock fires every wait immediately and records the requested delays.
+// Now advances by each delay waited for.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	waits  []time.Duration
+	frozen bool // if set, waits never fire
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.waits = append(c.waits, d)
+	ch := make(chan time.Time, 1)
+	if !c.frozen {
+		c.now = c.now.Add(d)
+		ch <- c.now
+	}
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func (c *fakeClock) Waits() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Duration(nil), c.waits...)
+}
+
+var errDeadlock = errors.New("deadlock detected")
+
+type permanentError struct{}
+
+func (permanentError) Error() string   { return "permanent" }
+func (permanentError) Retryable() bool { return false }
+
+// flaky fails with err for the first n calls.
+func flaky(n int, err error) (handler typemux.HandlerFunc[testEvent], calls *int) {
+	calls = new(int)
+	return func(ctx context.Context, e testEvent) error {
+		*calls++
+		if *calls <= n {
+			return err
+		}
+		return nil
+	}, calls
+}
+
+func TestRetry_Backoff(t *testing.T) {
+	clock := newFakeClock()
+	handler, calls := flaky(4, errDeadlock)
+
+	reg := typemux.NewRegistry()
+	typemux.RegisterDispatch(reg, handler, typemux.Retry[testEvent](typemux.RetryConfig{
+		MaxAttempts: 5,
+		BaseDelay:   100 * time.Millisecond,
+		MaxDelay:    300 * time.Millisecond,
+		Clock:       clock,
+	}))
+
+	if err := typemux.Dispatch(reg, context.Background(), testEvent{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *calls != 5 {
+		t.Errorf("expected 5 attempts, got %d", *calls)
+	}
+
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}
+	if got := clock.Waits(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected delays %v, got %v", want, got)
+	}
+}
+
+func TestRetry_Exhausted(t *testing.T) {
+	handler, calls := flaky(10, errDeadlock)
+
+	reg := typemux.NewRegistry()
+	typemux.RegisterDispatch(reg, handler)
+
+	err := typemux.Dispatch(reg, context.Background(), testEvent{},
+		typemux.RetryDispatch(typemux.RetryConfig{Clock: newFakeClock()}))
+
+	var exhausted *typemux.RetryExhaustedError
+	if !errors.As(err, &exhausted) {
+		t.Fatalf("expected *RetryExhaustedError, got %v", err)
+	}
+	if exhausted.Attempts != typemux.DefaultRetryAttempts || *calls != typemux.DefaultRetryAttempts {
+		t.Errorf("expected %d attempts, got %d (%d calls)", typemux.DefaultRetryAttempts, exhausted.Attempts, *calls)
+	}
+	if !errors.Is(err, errDeadlock) {
+		t.Errorf("expected the last error to unwrap, got %v", err)
+	}
+	const want = "typemux: giving up after 3 attempts: deadlock detected"
+	if err.Error() != want {
+		t.Errorf("expected %q, got %q", want, err.Error())
+	}
+}
+
+func TestRetry_Classification(t *testing.T) {
+	errOther := errors.New("other")
+
+	tests := []struct {
+		name      string
+		classify  typemux.RetryClassifier
+		err       error
+		wantCalls int
+	}{
+		{name: "default_retries_plain_errors", err: errOther, wantCalls: 3},
+		{name: "default_skips_not_retryable", err: permanentError{}, wantCalls: 1},
+		{name: "default_skips_context_errors", err: context.DeadlineExceeded, wantCalls: 1},
+		{name: "default_skips_lookup_errors", err: typemux.ErrAmbiguousHandler, wantCalls: 1},
+		{name: "retry_on_match", classify: typemux.RetryOn(errDeadlock), err: errDeadlock, wantCalls: 3},
+		{name: "retry_on_miss", classify: typemux.RetryOn(errDeadlock), err: errOther, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, calls := flaky(10, tt.err)
+			mw := typemux.Retry[testEvent](typemux.RetryConfig{Classify: tt.classify, Clock: newFakeClock()})
+
+			err := mw(handler)(context.Background(), testEvent{})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+			if *calls != tt.wantCalls {
+				t.Errorf("expected %d calls, got %d", tt.wantCalls, *calls)
+			}
+		})
+	}
+}
+
+func TestRetry_HandlerNotFound(t *testing.T) {
+	clock := newFakeClock()
+	reg := typemux.NewRegistry()
+
+	err := typemux.Dispatch(reg, context.Background(), testEvent{}, typemux.RetryDispatch(typemux.RetryConfig{Clock: clock}))
+	if !errors.Is(err, typemux.ErrHandlerNotFound) || errors.As(err, new(*typemux.RetryExhaustedError)) {
+		t.Errorf("expected ErrHandlerNotFound without retries, got %v", err)
+	}
+	if waits := clock.Waits(); len(waits) != 0 {
+		t.Errorf("expected no backoff, got %v", waits)
+	}
+}
+
+func TestRetry_ContextDone(t *testing.T) {
+	clock := newFakeClock()
+	clock.frozen = true
+	handler, calls := flaky(10, errDeadlock)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := typemux.Retry[testEvent](typemux.RetryConfig{Clock: clock})(handler)(ctx, testEvent{})
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, errDeadlock) {
+		t.Errorf("expected context error joined with the handler error, got %v", err)
+	}
+	if *calls != 1 {
+		t.Errorf("expected the wait to be cut short, got %d calls", *calls)
+	}
+}
+
+func TestRetry_Jitter(t *testing.T) {
+	clock := newFakeClock()
+	handler, _ := flaky(100, errDeadlock)
+
+	cfg := typemux.RetryConfig{MaxAttempts: 50, BaseDelay: time.Second, MaxDelay: time.Second, Jitter: 0.5, Clock: clock}
+	_ = typemux.Retry[testEvent](cfg)(handler)(context.Background(), testEvent{})
+
+	varied := false
+	for _, d := range clock.Waits() {
+		if d < 500*time.Millisecond || d > 1500*time.Millisecond {
+			t.Fatalf("delay %v outside ±50%% of 1s", d)
+		}
+		varied = varied || d != time.Second
+	}
+	if !varied {
+		t.Error("expected jittered delays")
+	}
+}
@@ -1,6 +1,7 @@
 package typemux
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
@@ -18,7 +19,7 @@ var ErrKeyTypeMismatch = errors.New("key type mismatch")
 // type, but none of them produce the DATA type requested by Serialize.
 var ErrDataTypeMismatch = errors.New("data type mismatch")
 
-type serializerFuncAny func(v any) (any, error)
+type serializerFuncAny func(ctx context.Context, v any) (any, error)
 
 type serializerEntry struct {
 	key any
@@ -28,6 +29,7 @@ type serializerEntry struct {
 type serializerResolver interface {
 	getSerializer(typ, dataType reflect.Type) (serializerEntry, bool)
 	typeRegistered(typ reflect.Type) bool
+	pointerPolicy() PointerPolicy
 }
 
 // Serialize looks up the codec's marshal half for v's concrete type and the
@@ -40,58 +42,65 @@ type serializerResolver interface {
 //	name, data, err := typemux.Marshal[string, []byte](sealed, value)
 //
 // If v is a pointer to a type registered by value, Serialize dereferences and
-// serializes the underlying value.
+// serializes the underlying value. Other fallbacks follow the registry's
+// PointerPolicy, as in Dispatch.
 //
 // Returns:
 //   - ErrSerializerNotFound if no codec is registered for the value's type
 //   - ErrDataTypeMismatch if the type is registered but not for the requested DATA
 //   - ErrKeyTypeMismatch if the requested KEY type doesn't match the registered key
 //   - ErrUnsupported if the codec's marshal half was Unsupported
+//
+// Every error is a *CodecError in the CodecMarshal direction.
 func Serialize[KEY comparable, DATA any](reg serializerResolver, v any) (KEY, DATA, error) {
+	return SerializeContext[KEY, DATA](reg, context.Background(), v)
+}
+
+// SerializeContext is Serialize with a context, passed on to the codec
+// middleware (see WithCodecMiddleware), e.g. to parent its trace spans.
+func SerializeContext[KEY comparable, DATA any](reg serializerResolver, ctx context.Context, v any) (KEY, DATA, error) {
 	var zeroK KEY
 	var zeroD DATA
 
 	typ := reflect.TypeOf(v)
-	if typ == nil {
-		return zeroK, zeroD, fmt.Errorf("typemux: %w for nil value", ErrSerializerNotFound)
-	}
-
 	dataType := reflect.TypeOf((*DATA)(nil)).Elem()
+	fail := func(key any, err error) (KEY, DATA, error) {
+		return zeroK, zeroD, &CodecError{Key: key, Type: typ, DataType: dataType, Direction: CodecMarshal, Err: err}
+	}
 
-	entry, ok := reg.getSerializer(typ, dataType)
-	if !ok && typ.Kind() == reflect.Ptr {
-		if e, ok2 := reg.getSerializer(typ.Elem(), dataType); ok2 {
-			entry = e
-			v = reflect.ValueOf(v).Elem().Interface()
-			ok = true
-		}
+	if typ == nil {
+		return fail(nil, fmt.Errorf("typemux: %w for nil value", ErrSerializerNotFound))
 	}
 
+	policy := reg.pointerPolicy()
+	entry, arg, ok := lookupPointer(typ, v, policy, func(t reflect.Type) (serializerEntry, bool) {
+		return reg.getSerializer(t, dataType)
+	})
+
 	if !ok {
 		// Differentiate "type unknown" from "type known but DATA mismatch".
-		probe := typ
-		if typ.Kind() == reflect.Ptr && reg.typeRegistered(typ.Elem()) {
-			probe = typ.Elem()
-		}
-		if reg.typeRegistered(probe) {
-			return zeroK, zeroD, fmt.Errorf("typemux: %w: type %v has no serializer producing %v", ErrDataTypeMismatch, probe, dataType)
+		probe, _, known := lookupPointer(typ, v, policy, func(t reflect.Type) (reflect.Type, bool) {
+			return t, reg.typeRegistered(t)
+		})
+		if known {
+			return fail(nil, fmt.Errorf("typemux: %w: type %v has no serializer producing %v", ErrDataTypeMismatch, probe, dataType))
 		}
-		return zeroK, zeroD, fmt.Errorf("typemux: %w for type %v", ErrSerializerNotFound, typ)
+		return fail(nil, fmt.Errorf("typemux: %w for type %v", ErrSerializerNotFound, typ))
 	}
 
 	k, ok := entry.key.(KEY)
 	if !ok {
-		return zeroK, zeroD, fmt.Errorf("typemux: %w: registered key is %T, requested %T", ErrKeyTypeMismatch, entry.key, zeroK)
+		return fail(entry.key, fmt.Errorf("typemux: %w: registered key is %T, requested %T", ErrKeyTypeMismatch, entry.key, zeroK))
 	}
 
-	result, err := entry.fn(v)
+	result, err := entry.fn(ctx, arg)
 	if err != nil {
-		return zeroK, zeroD, err
+		return fail(entry.key, err)
 	}
 
 	data, ok := result.(DATA)
 	if !ok {
-		return zeroK, zeroD, fmt.Errorf("typemux: %w: registered data is %T, requested %T", ErrDataTypeMismatch, result, zeroD)
+		return fail(entry.key, fmt.Errorf("typemux: %w: registered data is %T, requested %T", ErrDataTypeMismatch, result, zeroD))
 	}
 
 	return k, data, nil
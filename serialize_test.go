@@ -1,8 +1,11 @@
 package typemux_test
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"reflect"
+	"slices"
 	"testing"
 
 	"github.com/struct0x/typemux"
@@ -254,3 +257,73 @@ func TestSerialize_DataTypeMismatch(t *testing.T) {
 		t.Errorf("expected ErrDataTypeMismatch, got %v", err)
 	}
 }
+
+func TestWithCodecMiddleware(t *testing.T) {
+	var calls []string
+	trace := func(name string) typemux.CodecMiddleware {
+		return func(ctx context.Context, call typemux.CodecCall, in any, next func(context.Context, any) (any, error)) (any, error) {
+			calls = append(calls, name+":"+call.Direction.String()+":"+call.Key.(string))
+			return next(ctx, in)
+		}
+	}
+
+	reg := typemux.NewRegistry(typemux.WithCodecMiddleware(trace("outer")), typemux.WithCodecMiddleware(trace("inner")))
+	typemux.RegisterCodec(reg, "user", typemux.JSONCodec[UserCreated]())
+
+	roundTrip := func(ser func(any) (string, []byte, error), create func(string, []byte) (any, error)) error {
+		_, data, err := ser(UserCreated{ID: "1"})
+		if err != nil {
+			return err
+		}
+		_, err = create("user", data)
+		return err
+	}
+	sealed := reg.Seal()
+
+	for name, run := range map[string]func() error{
+		"standard_registry": func() error {
+			return roundTrip(
+				func(v any) (string, []byte, error) { return typemux.Serialize[string, []byte](reg, v) },
+				func(k string, d []byte) (any, error) { return typemux.CreateType(reg, k, d) })
+		},
+		"sealed_registry": func() error {
+			return roundTrip(
+				func(v any) (string, []byte, error) { return typemux.Serialize[string, []byte](sealed, v) },
+				func(k string, d []byte) (any, error) { return typemux.CreateType(sealed, k, d) })
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			calls = nil
+			if err := run(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			want := []string{"outer:marshal:user", "inner:marshal:user", "outer:unmarshal:user", "inner:unmarshal:user"}
+			if !slices.Equal(calls, want) {
+				t.Errorf("expected %v, got %v", want, calls)
+			}
+		})
+	}
+}
+
+func TestWithCodecMiddleware_Errors(t *testing.T) {
+	failing := func(ctx context.Context, call typemux.CodecCall, in any, next func(context.Context, any) (any, error)) (any, error) {
+		return nil, errCorrupt
+	}
+	reg := typemux.NewRegistry(typemux.WithCodecMiddleware(failing))
+	typemux.RegisterCodec(reg, "user", typemux.JSONCodec[UserCreated]())
+
+	_, err := typemux.CreateType(reg, "user", []byte("{}"))
+	var ce *typemux.CodecError
+	if !errors.As(err, &ce) || ce.Direction != typemux.CodecUnmarshal || ce.Key != "user" || ce.Type != reflect.TypeOf(UserCreated{}) {
+		t.Errorf("expected an unmarshal *CodecError, got %#v", err)
+	}
+	if !errors.Is(err, errCorrupt) {
+		t.Errorf("expected the middleware error, got %v", err)
+	}
+
+	_, _, err = typemux.Serialize[string, []byte](reg, UserCreated{})
+	if !errors.As(err, &ce) || ce.Direction != typemux.CodecMarshal || ce.Key != "user" || !errors.Is(err, errCorrupt) {
+		t.Errorf("expected a marshal *CodecError, got %#v", err)
+	}
+}
@@ -0,0 +1,238 @@
+package typemux
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LogConfig configures the log/slog middleware: LogDispatch, Log and
+// LogCodec.
+type LogConfig struct {
+	// Logger receives the records. Defaults to slog.Default().
+	Logger *slog.Logger
+	// Level is the level of successful calls. Defaults to slog.LevelDebug.
+	Level slog.Leveler
+	// ErrorLevel is the level of failed calls. Defaults to slog.LevelError.
+	ErrorLevel slog.Leveler
+	// Payload adds the handled value as a "payload" attribute: the dispatched
+	// value, or the value a codec produced or marshaled.
+	Payload bool
+	// Redact, if set, replaces the payload before it is logged, e.g. with
+	// RedactFields. Values implementing slog.LogValuer are resolved by slog
+	// as usual.
+	Redact func(v any) any
+	// Sample, if set, selects the successful calls that are logged. Failures
+	// are always logged.
+	Sample Sampler
+	// Clock measures durations. Defaults to the system clock.
+	Clock Clock
+}
+
+// Sampler decides whether a successful call on a value of typ is logged.
+type Sampler func(typ reflect.Type) bool
+
+// SampleEvery returns a Sampler keeping the first of every n calls, counted
+// per type.
+func SampleEvery(n int) Sampler {
+	var counters sync.Map // reflect.Type -> *atomic.Uint64
+	return func(typ reflect.Type) bool {
+		if n <= 1 {
+			return true
+		}
+		c, ok := counters.Load(typ)
+		if !ok {
+			c, _ = counters.LoadOrStore(typ, new(atomic.Uint64))
+		}
+		return (c.(*atomic.Uint64).Add(1)-1)%uint64(n) == 0
+	}
+}
+
+// SampleRate returns a Sampler keeping each call with probability p.
+func SampleRate(p float64) Sampler {
+	return func(reflect.Type) bool {
+		return rand.Float64() < p
+	}
+}
+
+// RedactFields returns a LogConfig.Redact function logging structs, and
+// pointers to them, as a group of their exported fields with the named
+// fields replaced by "[REDACTED]". Other values are logged unchanged.
+func RedactFields(names ...string) func(v any) any {
+	return func(v any) any {
+		rv := reflect.ValueOf(v)
+		for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+			rv = rv.Elem()
+		}
+		if rv.Kind() != reflect.Struct {
+			return v
+		}
+
+		rt := rv.Type()
+		attrs := make([]slog.Attr, 0, rt.NumField())
+		for i := range rt.NumField() {
+			f := rt.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			if slices.Contains(names, f.Name) {
+				attrs = append(attrs, slog.String(f.Name, "[REDACTED]"))
+			} else {
+				attrs = append(attrs, slog.Any(f.Name, rv.Field(i).Interface()))
+			}
+		}
+		return slog.GroupValue(attrs...)
+	}
+}
+
+// LogDispatch returns a DispatchMiddleware logging each dispatch with the
+// value's type, the duration and any error.
+func LogDispatch(cfg LogConfig) DispatchMiddleware {
+	l := newCallLogger(cfg)
+	return func(ctx context.Context, event any, next func(context.Context) error) error {
+		return l.dispatch(ctx, reflect.TypeOf(event), event, next)
+	}
+}
+
+// Log returns a typed Middleware logging each call of the handler for T,
+// like LogDispatch.
+func Log[T any](cfg LogConfig) Middleware[T] {
+	l := newCallLogger(cfg)
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	return func(next HandlerFunc[T]) HandlerFunc[T] {
+		return func(ctx context.Context, val T) error {
+			return l.dispatch(ctx, typ, val, func(ctx context.Context) error {
+				return next(ctx, val)
+			})
+		}
+	}
+}
+
+// LogCodec returns a CodecMiddleware logging each marshal and unmarshal with
+// the codec's key, type and data type, the duration and any error. Attach it
+// with WithCodecMiddleware.
+func LogCodec(cfg LogConfig) CodecMiddleware {
+	l := newCallLogger(cfg)
+	return func(ctx context.Context, call CodecCall, in any, next func(context.Context, any) (any, error)) (any, error) {
+		if !l.enabled(ctx) {
+			return next(ctx, in)
+		}
+
+		start := l.clock.Now()
+		out, err := next(ctx, in)
+
+		msg, payload, hasPayload := "typemux: marshal", in, true
+		if call.Direction == CodecUnmarshal {
+			msg, payload, hasPayload = "typemux: unmarshal", out, err == nil
+		}
+		l.log(ctx, msg, call.Type, start, err, payload, hasPayload,
+			slog.Any("key", call.Key), slog.String("type", typeName(call.Type)), slog.String("data_type", typeName(call.DataType)))
+		return out, err
+	}
+}
+
+// WithRegistrationLog logs RegisterDispatch and RegisterCodec to logger: new
+// registrations at debug level, and those replacing an existing registration
+// at info level.
+func WithRegistrationLog(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.regLog = logger
+	}
+}
+
+func (o *options) logRegistration(kind string, replaced bool, attrs ...slog.Attr) {
+	if o.regLog == nil {
+		return
+	}
+	msg, level := "typemux: "+kind+" registered", slog.LevelDebug
+	if replaced {
+		msg, level = "typemux: "+kind+" replaced", slog.LevelInfo
+	}
+	o.regLog.LogAttrs(context.Background(), level, msg, attrs...)
+}
+
+type callLogger struct {
+	logger   *slog.Logger
+	level    slog.Leveler
+	errLevel slog.Leveler
+	payload  bool
+	redact   func(any) any
+	sample   Sampler
+	clock    Clock
+}
+
+func newCallLogger(cfg LogConfig) *callLogger {
+	l := &callLogger{
+		logger:   cfg.Logger,
+		level:    cfg.Level,
+		errLevel: cfg.ErrorLevel,
+		payload:  cfg.Payload,
+		redact:   cfg.Redact,
+		sample:   cfg.Sample,
+		clock:    clockOrSystem(cfg.Clock),
+	}
+	if l.logger == nil {
+		l.logger = slog.Default()
+	}
+	if l.level == nil {
+		l.level = slog.LevelDebug
+	}
+	if l.errLevel == nil {
+		l.errLevel = slog.LevelError
+	}
+	return l
+}
+
+// enabled reports whether either outcome of a call would be logged.
+func (l *callLogger) enabled(ctx context.Context) bool {
+	return l.logger.Enabled(ctx, min(l.level.Level(), l.errLevel.Level()))
+}
+
+func (l *callLogger) dispatch(ctx context.Context, typ reflect.Type, v any, call func(context.Context) error) error {
+	if !l.enabled(ctx) {
+		return call(ctx)
+	}
+
+	start := l.clock.Now()
+	err := call(ctx)
+	l.log(ctx, "typemux: dispatch", typ, start, err, v, true, slog.String("type", typeName(typ)))
+	return err
+}
+
+func (l *callLogger) log(ctx context.Context, msg string, typ reflect.Type, start time.Time, err error, payload any, hasPayload bool, attrs ...slog.Attr) {
+	level := l.level.Level()
+	if err != nil {
+		level = l.errLevel.Level()
+	}
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+	if err == nil && l.sample != nil && !l.sample(typ) {
+		return
+	}
+
+	attrs = append(attrs, slog.Duration("duration", l.clock.Now().Sub(start)))
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	if l.payload && hasPayload {
+		if l.redact != nil {
+			payload = l.redact(payload)
+		}
+		attrs = append(attrs, slog.Any("payload", payload))
+	}
+	l.logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// typeName returns typ's name for log attributes; "<nil>" for a nil type.
+func typeName(typ reflect.Type) string {
+	if typ == nil {
+		return "<nil>"
+	}
+	return typ.String()
+}
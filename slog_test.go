@@ -0,0 +1,211 @@
+package typemux_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/struct0x/typemux"
+)
+
+// newLogBuffer returns a debug-level JSON logger and a function decoding the
+// records it wrote so far.
+func newLogBuffer(t *testing.T) (*slog.Logger, func() []map[string]any) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	return logger, func() []map[string]any {
+		t.Helper()
+
+		var records []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			var rec map[string]any
+			if err := json.Unmarshal([]byte(line), &rec); err != nil {
+				t.Fatalf("invalid record %q: %v", line, err)
+			}
+			records = append(records, rec)
+		}
+		return records
+	}
+}
+
+func TestLogDispatch(t *testing.T) {
+	logger, records := newLogBuffer(t)
+	clock := newFakeClock()
+
+	reg := typemux.NewRegistry()
+	typemux.RegisterDispatch(reg, func(ctx context.Context, u UserCreated) error {
+		clock.Advance(5 * time.Millisecond)
+		if u.ID == "bad" {
+			return errDeadlock
+		}
+		return nil
+	})
+
+	logging := typemux.LogDispatch(typemux.LogConfig{
+		Logger:  logger,
+		Payload: true,
+		Redact:  typemux.RedactFields("Name"),
+		Clock:   clock,
+	})
+	_ = typemux.Dispatch(reg, context.Background(), UserCreated{ID: "1", Name: "John"}, logging)
+	_ = typemux.Dispatch(reg, context.Background(), UserCreated{ID: "bad", Name: "Jane"}, logging)
+
+	recs := records()
+	if len(recs) != 2 {
+		t.Fatalf("expected 2 records, got %v", recs)
+	}
+
+	ok := recs[0]
+	if ok["level"] != "DEBUG" || ok["msg"] != "typemux: dispatch" || ok["type"] != "typemux_test.UserCreated" {
+		t.Errorf("unexpected record: %v", ok)
+	}
+	if ok["duration"] != float64(5*time.Millisecond) {
+		t.Errorf("expected a 5ms duration, got %v", ok["duration"])
+	}
+	payload, _ := ok["payload"].(map[string]any)
+	if payload["ID"] != "1" || payload["Name"] != "[REDACTED]" {
+		t.Errorf("expected a redacted payload, got %v", ok["payload"])
+	}
+	if _, found := ok["error"]; found {
+		t.Errorf("expected no error attribute, got %v", ok["error"])
+	}
+
+	failed := recs[1]
+	if failed["level"] != "ERROR" || failed["error"] != "deadlock detected" {
+		t.Errorf("unexpected record: %v", failed)
+	}
+}
+
+func TestLogDispatch_Sampling(t *testing.T) {
+	logger, records := newLogBuffer(t)
+
+	reg := typemux.NewRegistry()
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error {
+		if e.Name == "bad" {
+			return errDeadlock
+		}
+		return nil
+	})
+	typemux.RegisterDispatch(reg, func(ctx context.Context, o OrderPlaced) error { return nil }, typemux.Log[OrderPlaced](typemux.LogConfig{
+		Logger: logger,
+		Level:  slog.LevelInfo,
+		Sample: typemux.SampleEvery(2),
+	}))
+
+	logging := typemux.LogDispatch(typemux.LogConfig{Logger: logger, Sample: typemux.SampleEvery(3)})
+	for range 5 {
+		_ = typemux.Dispatch(reg, context.Background(), testEvent{}, logging)
+	}
+	_ = typemux.Dispatch(reg, context.Background(), testEvent{Name: "bad"}, logging)
+	for range 3 {
+		_ = typemux.Dispatch(reg, context.Background(), OrderPlaced{})
+	}
+
+	var levels []string
+	for _, rec := range records() {
+		levels = append(levels, rec["type"].(string)+"/"+rec["level"].(string))
+	}
+	want := []string{
+		"typemux_test.testEvent/DEBUG", // 1st
+		"typemux_test.testEvent/DEBUG", // 4th
+		"typemux_test.testEvent/ERROR", // failures are always logged
+		"typemux_test.OrderPlaced/INFO",
+		"typemux_test.OrderPlaced/INFO",
+	}
+	if strings.Join(levels, ",") != strings.Join(want, ",") {
+		t.Errorf("expected %v, got %v", want, levels)
+	}
+}
+
+func TestLogDispatch_Disabled(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	reg := typemux.NewRegistry()
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error { return nil })
+
+	logging := typemux.LogDispatch(typemux.LogConfig{Logger: logger})
+	if err := typemux.Dispatch(reg, context.Background(), testEvent{}, logging); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected debug records to be dropped, got %s", buf.String())
+	}
+}
+
+func TestLogCodec(t *testing.T) {
+	logger, records := newLogBuffer(t)
+
+	reg := typemux.NewRegistry(typemux.WithCodecMiddleware(typemux.LogCodec(typemux.LogConfig{Logger: logger, Payload: true})))
+	typemux.RegisterCodec(reg, "order_placed", typemux.JSONCodec[OrderPlaced]())
+
+	_, data, err := typemux.Serialize[string, []byte](reg, OrderPlaced{OrderID: "o1"})
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if _, err := typemux.CreateType(reg, "order_placed", data); err != nil {
+		t.Fatalf("CreateType failed: %v", err)
+	}
+	if _, err := typemux.CreateType(reg, "order_placed", []byte("{")); err == nil {
+		t.Fatal("expected an unmarshal error")
+	}
+
+	recs := records()
+	if len(recs) != 3 {
+		t.Fatalf("expected 3 records, got %v", recs)
+	}
+	for i, msg := range []string{"typemux: marshal", "typemux: unmarshal", "typemux: unmarshal"} {
+		rec := recs[i]
+		if rec["msg"] != msg || rec["key"] != "order_placed" || rec["type"] != "typemux_test.OrderPlaced" || rec["data_type"] != "[]uint8" {
+			t.Errorf("record %d: unexpected record %v", i, rec)
+		}
+	}
+	if payload, _ := recs[1]["payload"].(map[string]any); payload["order_id"] != "o1" {
+		t.Errorf("expected the created value as payload, got %v", recs[1]["payload"])
+	}
+	if recs[2]["level"] != "ERROR" || recs[2]["error"] == nil || recs[2]["payload"] != nil {
+		t.Errorf("expected an error record without payload, got %v", recs[2])
+	}
+}
+
+func TestWithRegistrationLog(t *testing.T) {
+	logger, records := newLogBuffer(t)
+
+	reg := typemux.NewRegistry(typemux.WithRegistrationLog(logger))
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error { return nil })
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error { return nil })
+	typemux.RegisterCodec(reg, "order_placed", typemux.JSONCodec[OrderPlaced]())
+	typemux.RegisterCodec(reg, "order_placed", typemux.JSONCodec[OrderPlaced]())
+
+	var got []string
+	for _, rec := range records() {
+		got = append(got, rec["level"].(string)+" "+rec["msg"].(string)+" "+rec["type"].(string))
+	}
+	want := []string{
+		"DEBUG typemux: handler registered typemux_test.testEvent",
+		"INFO typemux: handler replaced typemux_test.testEvent",
+		"DEBUG typemux: codec registered typemux_test.OrderPlaced",
+		"INFO typemux: codec replaced typemux_test.OrderPlaced",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,323 @@
+package typemux
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidTraceparent is returned by ParseTraceparent and
+// ExtractTraceparent for a malformed W3C traceparent header.
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+// Span attribute keys set by the tracing middleware.
+const (
+	SpanAttrType     = "typemux.type"
+	SpanAttrKey      = "typemux.key"
+	SpanAttrDataType = "typemux.data_type"
+)
+
+// SpanAttr is a key-value attribute of a Span.
+type SpanAttr struct {
+	Key   string
+	Value any
+}
+
+// Tracer starts spans. Implement it to bridge typemux to a tracing library,
+// or use RecordingTracer in tests.
+type Tracer interface {
+	// Start starts a span named name. Its parent is the span whose
+	// SpanContext ctx carries, if any (see SpanContextFromContext).
+	Start(ctx context.Context, name string, attrs ...SpanAttr) Span
+}
+
+// Span is an operation being traced.
+type Span interface {
+	// SpanContext identifies the span, for propagation to its children.
+	SpanContext() SpanContext
+	SetAttributes(attrs ...SpanAttr)
+	// SetError marks the span as failed with err.
+	SetError(err error)
+	// End completes the span; later calls have no effect.
+	End()
+}
+
+// SpanContext identifies a span across process boundaries, as in a W3C
+// traceparent header.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+// IsValid reports whether sc has non-zero trace and span IDs.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Traceparent formats sc as a version 00 W3C traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-" + flags
+}
+
+// ParseTraceparent parses a W3C traceparent header value. Versions above 00
+// are accepted as long as they start with the version 00 fields.
+func ParseTraceparent(s string) (SpanContext, error) {
+	invalid := func() (SpanContext, error) {
+		return SpanContext{}, fmt.Errorf("typemux: %w: %q", ErrInvalidTraceparent, s)
+	}
+
+	parts := strings.Split(s, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return invalid()
+	}
+	if s != strings.ToLower(s) || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return invalid()
+	}
+
+	var sc SpanContext
+	var version, flags [1]byte
+	for _, field := range []struct {
+		dst []byte
+		src string
+	}{
+		{version[:], parts[0]},
+		{sc.TraceID[:], parts[1]},
+		{sc.SpanID[:], parts[2]},
+		{flags[:], parts[3]},
+	} {
+		if _, err := hex.Decode(field.dst, []byte(field.src)); err != nil {
+			return invalid()
+		}
+	}
+	if !sc.IsValid() {
+		return invalid()
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, nil
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpanContext returns a copy of ctx carrying sc as the current
+// span.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the current span carried by ctx.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok
+}
+
+// InjectTraceparent returns the traceparent header value of the current span
+// in ctx, to be stored in an outgoing envelope; "" if there is none.
+func InjectTraceparent(ctx context.Context) string {
+	sc, ok := SpanContextFromContext(ctx)
+	if !ok || !sc.IsValid() {
+		return ""
+	}
+	return sc.Traceparent()
+}
+
+// ExtractTraceparent returns a copy of ctx whose current span is the one
+// described by traceparent, read from an incoming envelope. Spans started
+// from the returned context continue the sender's trace.
+func ExtractTraceparent(ctx context.Context, traceparent string) (context.Context, error) {
+	sc, err := ParseTraceparent(traceparent)
+	if err != nil {
+		return ctx, err
+	}
+	return ContextWithSpanContext(ctx, sc), nil
+}
+
+// TraceDispatch returns a DispatchMiddleware running each dispatch in a
+// "typemux.dispatch" span, with the value's type as an attribute. The
+// handler's context carries the span, so nested dispatches become its
+// children.
+func TraceDispatch(tracer Tracer) DispatchMiddleware {
+	return func(ctx context.Context, event any, next func(context.Context) error) error {
+		return traceDispatch(tracer, ctx, reflect.TypeOf(event), next)
+	}
+}
+
+// Trace returns a typed Middleware running each call of the handler for T in
+// a span, like TraceDispatch.
+func Trace[T any](tracer Tracer) Middleware[T] {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	return func(next HandlerFunc[T]) HandlerFunc[T] {
+		return func(ctx context.Context, val T) error {
+			return traceDispatch(tracer, ctx, typ, func(ctx context.Context) error {
+				return next(ctx, val)
+			})
+		}
+	}
+}
+
+// TraceCodec returns a CodecMiddleware running each unmarshal and marshal in
+// a "typemux.unmarshal" or "typemux.marshal" span, with the codec's key, type
+// and data type as attributes. Attach it with WithCodecMiddleware, and use
+// CreateTypeContext and SerializeContext to parent the spans.
+func TraceCodec(tracer Tracer) CodecMiddleware {
+	return func(ctx context.Context, call CodecCall, in any, next func(context.Context, any) (any, error)) (any, error) {
+		name := "typemux.marshal"
+		if call.Direction == CodecUnmarshal {
+			name = "typemux.unmarshal"
+		}
+
+		span := tracer.Start(ctx, name,
+			SpanAttr{Key: SpanAttrKey, Value: call.Key},
+			SpanAttr{Key: SpanAttrType, Value: typeName(call.Type)},
+			SpanAttr{Key: SpanAttrDataType, Value: typeName(call.DataType)},
+		)
+		defer span.End()
+
+		out, err := next(ContextWithSpanContext(ctx, span.SpanContext()), in)
+		if err != nil {
+			span.SetError(err)
+		}
+		return out, err
+	}
+}
+
+func traceDispatch(tracer Tracer, ctx context.Context, typ reflect.Type, call func(context.Context) error) error {
+	span := tracer.Start(ctx, "typemux.dispatch", SpanAttr{Key: SpanAttrType, Value: typeName(typ)})
+	defer span.End()
+
+	err := call(ContextWithSpanContext(ctx, span.SpanContext()))
+	if err != nil {
+		span.SetError(err)
+	}
+	return err
+}
+
+// RecordedSpan is a span ended on a RecordingTracer.
+type RecordedSpan struct {
+	Name        string
+	SpanContext SpanContext
+	// Parent is the parent span; zero for a root span.
+	Parent     SpanContext
+	Attrs      []SpanAttr
+	Err        error
+	Start, End time.Time
+}
+
+// Attr returns the value of the attribute named key, if set.
+func (s RecordedSpan) Attr(key string) (any, bool) {
+	for _, a := range slices.Backward(s.Attrs) {
+		if a.Key == key {
+			return a.Value, true
+		}
+	}
+	return nil, false
+}
+
+// RecordingTracer is an in-memory Tracer keeping every ended span, for tests.
+// Spans without a parent start a new sampled trace.
+type RecordingTracer struct {
+	clock Clock
+
+	mu    sync.Mutex
+	spans []RecordedSpan
+}
+
+// NewRecordingTracer creates a RecordingTracer timing spans with clock, or
+// the system clock if nil.
+func NewRecordingTracer(clock Clock) *RecordingTracer {
+	return &RecordingTracer{clock: clockOrSystem(clock)}
+}
+
+// Start implements Tracer.
+func (t *RecordingTracer) Start(ctx context.Context, name string, attrs ...SpanAttr) Span {
+	span := &recordingSpan{tracer: t}
+	span.rec = RecordedSpan{Name: name, Attrs: slices.Clone(attrs), Start: t.clock.Now()}
+
+	if parent, ok := SpanContextFromContext(ctx); ok && parent.IsValid() {
+		span.rec.Parent = parent
+		span.rec.SpanContext.TraceID = parent.TraceID
+		span.rec.SpanContext.Sampled = parent.Sampled
+	} else {
+		_, _ = rand.Read(span.rec.SpanContext.TraceID[:])
+		span.rec.SpanContext.Sampled = true
+	}
+	_, _ = rand.Read(span.rec.SpanContext.SpanID[:])
+	return span
+}
+
+// Spans returns the spans ended so far, in the order they ended.
+func (t *RecordingTracer) Spans() []RecordedSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return slices.Clone(t.spans)
+}
+
+// Reset discards the recorded spans.
+func (t *RecordingTracer) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.spans = nil
+}
+
+type recordingSpan struct {
+	tracer *RecordingTracer
+
+	mu    sync.Mutex
+	rec   RecordedSpan
+	ended bool
+}
+
+func (s *recordingSpan) SpanContext() SpanContext {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.rec.SpanContext
+}
+
+func (s *recordingSpan) SetAttributes(attrs ...SpanAttr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.ended {
+		s.rec.Attrs = append(s.rec.Attrs, attrs...)
+	}
+}
+
+func (s *recordingSpan) SetError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.ended {
+		s.rec.Err = err
+	}
+}
+
+func (s *recordingSpan) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.rec.End = s.tracer.clock.Now()
+	rec := s.rec
+	s.mu.Unlock()
+
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+
+	s.tracer.spans = append(s.tracer.spans, rec)
+}
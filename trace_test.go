@@ -0,0 +1,166 @@
+package typemux_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/struct0x/typemux"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestTraceDispatch(t *testing.T) {
+	clock := newFakeClock()
+	tracer := typemux.NewRecordingTracer(clock)
+
+	reg := typemux.NewRegistry()
+	var injected string
+	typemux.RegisterDispatch(reg, func(ctx context.Context, o OrderPlaced) error {
+		clock.Advance(time.Millisecond)
+		injected = typemux.InjectTraceparent(ctx)
+		return typemux.Dispatch(reg, ctx, testEvent{Name: o.OrderID})
+	})
+	typemux.RegisterDispatch(reg, func(ctx context.Context, e testEvent) error {
+		if e.Name == "bad" {
+			return errDeadlock
+		}
+		return nil
+	}, typemux.Trace[testEvent](tracer))
+
+	for name, disp := range map[string]func(v any) error{
+		"standard_registry": func(v any) error {
+			return typemux.Dispatch(reg, context.Background(), v, typemux.TraceDispatch(tracer))
+		},
+		"sealed_registry": func(v any) error {
+			return typemux.Dispatch(reg.Seal(), context.Background(), v, typemux.TraceDispatch(tracer))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tracer.Reset()
+
+			if err := disp(OrderPlaced{OrderID: "ok"}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := disp(OrderPlaced{OrderID: "bad"}); !errors.Is(err, errDeadlock) {
+				t.Fatalf("expected the handler error, got %v", err)
+			}
+
+			spans := tracer.Spans()
+			if len(spans) != 4 {
+				t.Fatalf("expected 4 spans, got %d", len(spans))
+			}
+			inner, outer := spans[0], spans[1]
+			if inner.Name != "typemux.dispatch" || outer.Name != "typemux.dispatch" {
+				t.Errorf("unexpected span names %q and %q", inner.Name, outer.Name)
+			}
+			if typ, _ := inner.Attr(typemux.SpanAttrType); typ != "typemux_test.testEvent" {
+				t.Errorf("expected the inner span's type attribute, got %v", typ)
+			}
+			if typ, _ := outer.Attr(typemux.SpanAttrType); typ != "typemux_test.OrderPlaced" {
+				t.Errorf("expected the outer span's type attribute, got %v", typ)
+			}
+			if outer.Parent.IsValid() {
+				t.Errorf("expected a root span, got parent %v", outer.Parent)
+			}
+			if inner.Parent != outer.SpanContext || inner.SpanContext.TraceID != outer.SpanContext.TraceID {
+				t.Errorf("expected the inner span to be a child of the outer span")
+			}
+			if outer.End.Sub(outer.Start) != time.Millisecond {
+				t.Errorf("expected a 1ms span, got %v", outer.End.Sub(outer.Start))
+			}
+			if outer.Err != nil || inner.Err != nil {
+				t.Errorf("expected no errors, got %v and %v", outer.Err, inner.Err)
+			}
+
+			if !errors.Is(spans[2].Err, errDeadlock) || !errors.Is(spans[3].Err, errDeadlock) {
+				t.Errorf("expected the failure recorded on both spans, got %v and %v", spans[2].Err, spans[3].Err)
+			}
+			if injected != spans[3].SpanContext.Traceparent() {
+				t.Errorf("expected the handler's context to carry its span, got %q", injected)
+			}
+		})
+	}
+}
+
+func TestTraceCodec(t *testing.T) {
+	tracer := typemux.NewRecordingTracer(nil)
+
+	reg := typemux.NewRegistry(typemux.WithCodecMiddleware(typemux.TraceCodec(tracer)))
+	typemux.RegisterCodec(reg, "order_placed", typemux.JSONCodec[OrderPlaced]())
+
+	ctx, err := typemux.ExtractTraceparent(context.Background(), testTraceparent)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	parent, _ := typemux.SpanContextFromContext(ctx)
+
+	_, data, err := typemux.SerializeContext[string, []byte](reg, ctx, OrderPlaced{OrderID: "o1"})
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if _, err := typemux.CreateTypeContext(reg.Seal(), ctx, "order_placed", data); err != nil {
+		t.Fatalf("CreateType failed: %v", err)
+	}
+	if _, err := typemux.CreateType(reg, "order_placed", []byte("{")); err == nil {
+		t.Fatal("expected an unmarshal error")
+	}
+
+	spans := tracer.Spans()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	for i, name := range []string{"typemux.marshal", "typemux.unmarshal", "typemux.unmarshal"} {
+		s := spans[i]
+		key, _ := s.Attr(typemux.SpanAttrKey)
+		typ, _ := s.Attr(typemux.SpanAttrType)
+		dataType, _ := s.Attr(typemux.SpanAttrDataType)
+		if s.Name != name || key != "order_placed" || typ != "typemux_test.OrderPlaced" || dataType != "[]uint8" {
+			t.Errorf("span %d: unexpected span %+v", i, s)
+		}
+	}
+	if spans[0].Parent != parent || spans[1].Parent != parent {
+		t.Errorf("expected the extracted span as parent")
+	}
+	if spans[2].Parent.IsValid() || spans[2].Err == nil {
+		t.Errorf("expected a failed root span, got %+v", spans[2])
+	}
+}
+
+func TestTraceparent(t *testing.T) {
+	sc, err := typemux.ParseTraceparent(testTraceparent)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !sc.Sampled || sc.Traceparent() != testTraceparent {
+		t.Errorf("expected a sampled round trip, got %+v as %q", sc, sc.Traceparent())
+	}
+
+	ctx := typemux.ContextWithSpanContext(context.Background(), sc)
+	if got := typemux.InjectTraceparent(ctx); got != testTraceparent {
+		t.Errorf("expected %q, got %q", testTraceparent, got)
+	}
+	if got := typemux.InjectTraceparent(context.Background()); got != "" {
+		t.Errorf("expected no traceparent, got %q", got)
+	}
+
+	if _, err := typemux.ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra"); err != nil {
+		t.Errorf("expected a future version to parse, got %v", err)
+	}
+
+	for _, s := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+	} {
+		if _, err := typemux.ExtractTraceparent(context.Background(), s); !errors.Is(err, typemux.ErrInvalidTraceparent) {
+			t.Errorf("%q: expected ErrInvalidTraceparent, got %v", s, err)
+		}
+	}
+}